@@ -41,8 +41,12 @@ func main() {
 	port := flag.Int("port", 8000, "the port number to listen on")
 	flag.Parse()
 
+	// Read all our settings before creating the provider, which checks
+	// them all.
 	whisperAddr := utils.StringFromEnv("WHISPER_ADDRESS", "")
 	enablePrometheus := utils.BoolFromEnv("ENABLE_PROMETHEUS", true)
+	nodeNumber := utils.IntFromEnv("WHISPER_NODE_NUMBER", 0)
+	processNumber := utils.IntFromEnv("WHISPER_PROCESS_NUMBER", 0)
 
 	// Order matters here: you MUST call SetHTTPGetHandler _after_
 	// creating the BaseHTTPServer. Yuck.
@@ -56,16 +60,13 @@ func main() {
 	server := faces.NewBaseHTTPServer(&gprv.BaseProvider)
 
 	if whisperAddr != "" {
-		nodeNumber := utils.IntFromEnv("WHISPER_NODE_NUMBER", 0)
-		processNumber := utils.IntFromEnv("WHISPER_PROCESS_NUMBER", 0)
-
 		gprv.EnableWhisper(whisperAddr, "gui", nodeNumber, processNumber)
 	}
 
 	gprv.SetHTTPGetHandler(gprv.HTTPGetHandler)
 
 	if enablePrometheus {
-		faces.StartPrometheusServer()
+		gprv.StartMetricsServer()
 	}
 
 	err = server.Start(fmt.Sprintf(":%d", *port))
@@ -22,7 +22,6 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
-	"strconv"
 	"time"
 
 	"fmt"
@@ -43,21 +42,18 @@ var (
 func main() {
 	utils.InitLogging()
 
-	target := os.Getenv("LOAD_TARGET")
-	rps := os.Getenv("LOAD_RPS")
+	target := utils.Env.String("LOAD_TARGET", "")
+	rpsInt := utils.Env.Int("LOAD_RPS", 0)
+	debug := utils.Env.Bool("LOAD_DEBUG", false)
 
-	if target == "" || rps == "" {
-		slog.Error(fmt.Sprintf("%s: LOAD_TARGET and LOAD_RPS must be set", Name))
-		os.Exit(1)
+	if target == "" {
+		utils.Env.Check("LOAD_TARGET", fmt.Errorf("must be set"))
 	}
 
-	debug, _ := strconv.ParseBool(os.Getenv("LOAD_DEBUG"))
-
-	// Convert rps to an integer
-	rpsInt, err := strconv.Atoi(rps)
-	if err != nil {
-		slog.Error("%s: failed to convert rps to an integer: %v", Name, err)
-		os.Exit(1)
+	if !utils.Env.IsSet("LOAD_RPS") {
+		utils.Env.Check("LOAD_RPS", fmt.Errorf("must be set"))
+	} else if rpsInt <= 0 {
+		utils.Env.Check("LOAD_RPS", fmt.Errorf("must be positive"))
 	}
 
 	hostName, err := os.Hostname()
@@ -68,6 +64,19 @@ func main() {
 
 	hostName = utils.StringFromEnv("HOSTNAME", hostName)
 
+	// load doesn't have a provider to call FinishSetup, so it checks its
+	// settings itself.
+	for _, unknown := range utils.Env.Unknown() {
+		slog.Warn(fmt.Sprintf("%s: unknown setting %s", Name, unknown))
+	}
+
+	err = utils.Env.Err()
+
+	if err != nil {
+		slog.Error(fmt.Sprintf("%s: invalid settings:\n%s", Name, err))
+		os.Exit(1)
+	}
+
 	requestsTotal := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "requests_total",
@@ -41,25 +41,31 @@ func main() {
 	port := flag.Int("port", 8000, "the port number to listen on")
 	flag.Parse()
 
+	// Read all our settings before creating the provider, which checks
+	// them all.
 	whisperAddr := utils.StringFromEnv("WHISPER_ADDRESS", "")
 	enablePrometheus := utils.BoolFromEnv("ENABLE_PROMETHEUS", true)
+	nodeNumber := utils.IntFromEnv("WHISPER_NODE_NUMBER", 0)
+	processNumber := utils.IntFromEnv("WHISPER_PROCESS_NUMBER", 2)
 
-	sprv := faces.NewSmileyProviderFromEnvironment()
+	sprv, err := faces.NewSmileyProviderFromEnvironment()
 
-	if whisperAddr != "" {
-		nodeNumber := utils.IntFromEnv("WHISPER_NODE_NUMBER", 0)
-		processNumber := utils.IntFromEnv("WHISPER_PROCESS_NUMBER", 2)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Unable to create SmileyProvider: %v", err))
+		os.Exit(1)
+	}
 
+	if whisperAddr != "" {
 		sprv.EnableWhisper(whisperAddr, "smiley", nodeNumber, processNumber)
 	}
 
 	if enablePrometheus {
-		faces.StartPrometheusServer()
+		sprv.StartMetricsServer()
 	}
 
 	server := faces.NewBaseHTTPServer(&sprv.BaseProvider)
 
-	err := server.Start(fmt.Sprintf(":%d", *port))
+	err = server.Start(fmt.Sprintf(":%d", *port))
 
 	if err != nil {
 		slog.Error(fmt.Sprintf("Unable to serve HTTP: %v", err))
@@ -52,15 +52,22 @@ func main() {
 	port := flag.Int("port", 8000, "the port number to listen on")
 	flag.Parse()
 
+	// Read all our settings before creating the provider, which checks
+	// them all.
 	whisperAddr := utils.StringFromEnv("WHISPER_ADDRESS", "")
 	enablePrometheus := utils.BoolFromEnv("ENABLE_PROMETHEUS", true)
+	adminPort := utils.IntFromEnv("ADMIN_PORT", 8001)
+	nodeNumber := utils.IntFromEnv("WHISPER_NODE_NUMBER", 0)
+	processNumber := utils.IntFromEnv("WHISPER_PROCESS_NUMBER", 0)
 
-	cprv := faces.NewColorProviderFromEnvironment()
+	cprv, err := faces.NewColorProviderFromEnvironment()
 
-	if whisperAddr != "" {
-		nodeNumber := utils.IntFromEnv("WHISPER_NODE_NUMBER", 0)
-		processNumber := utils.IntFromEnv("WHISPER_PROCESS_NUMBER", 0)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Unable to create ColorProvider: %v", err))
+		os.Exit(1)
+	}
 
+	if whisperAddr != "" {
 		cprv.EnableWhisper(whisperAddr, "color", nodeNumber, processNumber)
 	}
 
@@ -73,7 +80,13 @@ func main() {
 	server := faces.NewColorServer(cprv)
 
 	if enablePrometheus {
-		faces.StartPrometheusServer()
+		cprv.StartMetricsServer()
+	}
+
+	// gRPC doesn't give us anywhere to hang the admin API, so color gets a
+	// separate HTTP listener for it.
+	if adminPort > 0 {
+		faces.StartAdminServer(&cprv.BaseProvider, fmt.Sprintf(":%d", adminPort))
 	}
 
 	err = server.Start(*port)
@@ -49,15 +49,20 @@ func main() {
 	port := flag.Int("port", 8000, "the port number to listen on")
 	flag.Parse()
 
+	// Read all our settings before creating the provider, which checks
+	// them all.
 	whisperAddr := utils.StringFromEnv("WHISPER_ADDRESS", "")
 	enablePrometheus := utils.BoolFromEnv("ENABLE_PROMETHEUS", true)
+	nodeNumber := utils.IntFromEnv("WHISPER_NODE_NUMBER", 0)
+	processNumber := utils.IntFromEnv("WHISPER_PROCESS_NUMBER", 1)
 
-	sprv := faces.NewSmileyProviderFromEnvironment()
+	sprv, err := faces.NewSmileyProviderFromEnvironment()
 
-	if whisperAddr != "" {
-		nodeNumber := utils.IntFromEnv("WHISPER_NODE_NUMBER", 0)
-		processNumber := utils.IntFromEnv("WHISPER_PROCESS_NUMBER", 1)
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Unable to create SmileyProvider: %s", err))
+	}
 
+	if whisperAddr != "" {
 		sprv.EnableWhisper(whisperAddr, "smiley", nodeNumber, processNumber)
 	}
 
@@ -67,13 +72,15 @@ func main() {
 
 	hw.Watch(sprv.ErrorFraction(), sprv.IsLatched())
 
-	server := faces.NewBaseHTTPServer(&sprv.BaseProvider)
-	server.Start(fmt.Sprintf(":%d", *port))
-
 	if enablePrometheus {
-		faces.StartPrometheusServer()
+		sprv.StartMetricsServer()
 	}
 
-	// Wait for servers to finish
-	select {}
+	server := faces.NewBaseHTTPServer(&sprv.BaseProvider)
+
+	err = server.Start(fmt.Sprintf(":%d", *port))
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Unable to serve HTTP: %s", err))
+	}
 }
@@ -0,0 +1,165 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// RegisterAdminHandlers adds the admin API endpoints to a ServeMux. The
+// BaseHTTPServer does this on its own mux; gRPC workloads use
+// StartAdminServer to get a separate HTTP listener for it.
+//
+// The fault endpoints only exist for providers that have fault settings;
+// for the others (like the GUI), they're a 404 rather than something that
+// looks like it worked but did nothing.
+func (bprv *BaseProvider) RegisterAdminHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/admin/faults", bprv.faultsOnly(bprv.HandleAdminFaults))
+	mux.HandleFunc("/admin/rules", bprv.faultsOnly(bprv.HandleAdminRules))
+	mux.HandleFunc("/admin/unlatch", bprv.faultsOnly(bprv.HandleAdminUnlatch))
+	mux.HandleFunc("/admin/ready", bprv.HandleAdminReady)
+	mux.HandleFunc("/admin/warmup", bprv.faultsOnly(bprv.HandleAdminWarmup))
+	mux.HandleFunc("/admin/leak", bprv.faultsOnly(bprv.HandleAdminLeak))
+	mux.HandleFunc("/debug/latency", bprv.faultsOnly(bprv.HandleDebugLatency))
+	mux.HandleFunc("/config", bprv.HandleConfig)
+}
+
+// faultsOnly wraps an admin handler so that it answers 404 unless the
+// provider has fault settings.
+func (bprv *BaseProvider) faultsOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !bprv.faultsEnabled {
+			adminError(w, http.StatusNotFound, fmt.Sprintf("%s has no fault settings", bprv.Name))
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// StartAdminServer starts a standalone HTTP server for the admin API (and
+// the health checks). This is for workloads (like color) that don't
+// otherwise speak HTTP.
+func StartAdminServer(prv *BaseProvider, addr string) {
+	mux := http.NewServeMux()
+	prv.RegisterHealthHandlers(mux)
+	prv.RegisterAdminHandlers(mux)
+
+	adminServer := &http.Server{
+		Handler: mux,
+		Addr:    addr,
+	}
+
+	prv.Infof("Starting admin server on %s", addr)
+
+	go func() {
+		if err := adminServer.ListenAndServe(); err != http.ErrServerClosed {
+			prv.Warnf("Admin server failed: %v", err)
+		}
+	}()
+
+	prv.OnShutdown(func(ctx context.Context) {
+		adminServer.Shutdown(ctx)
+	})
+}
+
+// HandleAdminFaults implements /admin/faults. GET returns the current fault
+// settings, PUT replaces all of them, and PATCH changes only the fields
+// that are present in the request body.
+func (bprv *BaseProvider) HandleAdminFaults(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		// Nothing to do here, we'll just return the settings below.
+
+	case http.MethodPut:
+		var fs FaultSettings
+
+		err := decodeAdminJSON(r, &fs)
+
+		if err != nil {
+			adminError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err))
+			return
+		}
+
+		bprv.recordChanges(OriginAdminAPI, func() {
+			err = bprv.SetFaultSettings(fs)
+		})
+
+		if err != nil {
+			adminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+	case http.MethodPatch:
+		var patch FaultSettingsPatch
+
+		err := decodeAdminJSON(r, &patch)
+
+		if err != nil {
+			adminError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err))
+			return
+		}
+
+		bprv.recordChanges(OriginAdminAPI, func() {
+			_, err = bprv.UpdateFaultSettings(patch)
+		})
+
+		if err != nil {
+			adminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+	default:
+		adminError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+		return
+	}
+
+	adminJSON(w, http.StatusOK, bprv.FaultSettings())
+}
+
+// decodeAdminJSON decodes a request body into target, refusing any fields
+// that target doesn't know about -- a typo in an admin request should be an
+// error, not a silent no-op.
+func decodeAdminJSON(r *http.Request, target interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	return decoder.Decode(target)
+}
+
+func adminJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	respJSON, err := json.Marshal(value)
+
+	if err != nil {
+		adminError(w, http.StatusInternalServerError, fmt.Sprintf("failed to marshal response: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(respJSON)
+}
+
+func adminError(w http.ResponseWriter, statusCode int, message string) {
+	adminJSON(w, statusCode, map[string]interface{}{
+		"errors": []string{message},
+	})
+}
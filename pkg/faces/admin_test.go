@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A provider without fault settings (like the GUI) doesn't pretend to have
+// a fault API.
+func TestAdminFaultsOnlyWithFaults(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		bprv := newTestProvider()
+		bprv.faultsEnabled = enabled
+
+		mux := http.NewServeMux()
+		bprv.RegisterAdminHandlers(mux)
+
+		want := http.StatusNotFound
+
+		if enabled {
+			want = http.StatusOK
+		}
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/faults", strings.NewReader(`{"errorFraction": 20}`))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		if rec.Code != want {
+			t.Errorf("faultsEnabled %v: got status %d, want %d", enabled, rec.Code, want)
+		}
+
+		if !enabled && bprv.FaultSettings().ErrorFraction != 0 {
+			t.Errorf("faultsEnabled %v: PUT changed the fault settings", enabled)
+		}
+	}
+}
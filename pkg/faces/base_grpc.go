@@ -19,18 +19,21 @@ package faces
 
 import (
 	context "context"
+	"net"
 	"time"
 
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
-func grpcMetadata(ctx context.Context, prv *BaseProvider) (string, string, error) {
+func grpcMetadata(ctx context.Context, prv *BaseProvider) (string, string, string, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 
 	if !ok {
-		return "", "", status.Errorf(codes.DataLoss, "failed to get metadata")
+		return "", "", "", status.Errorf(codes.DataLoss, "failed to get metadata")
 	}
 
 	user := ""
@@ -47,12 +50,37 @@ func grpcMetadata(ctx context.Context, prv *BaseProvider) (string, string, error
 		userAgent = userAgents[0]
 	}
 
-	return user, userAgent, nil
+	fault := ""
+	faults := md.Get(prv.GetFaultHeaderName())
+
+	if len(faults) > 0 {
+		fault = faults[0]
+	}
+
+	return user, userAgent, fault, nil
+}
+
+// grpcClientAddress returns the address of the gRPC client, without the
+// port, or "unknown" if we can't tell.
+func grpcClientAddress(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
 }
 
 func HandleGRPC(ctx context.Context, prv *BaseProvider, subrequest string, row, col int) (*ProviderResponse, error) {
 	start := time.Now()
-	user, userAgent, err := grpcMetadata(ctx, prv)
+	user, userAgent, fault, err := grpcMetadata(ctx, prv)
 
 	if err != nil {
 		return nil, status.Errorf(codes.DataLoss, "failed to get user")
@@ -64,9 +92,60 @@ func HandleGRPC(ctx context.Context, prv *BaseProvider, subrequest string, row,
 		userAgent:  userAgent,
 		row:        row,
 		col:        col,
+		fault:      fault,
+		clientAddr: grpcClientAddress(ctx),
+		ctx:        ctx,
 	}
 
 	resp := prv.HandleRequest(start, prvReq)
 
+	// Compress the response if the client accepts any of our encodings.
+	encodings := prv.compressionEncodings()
+
+	if len(encodings) > 0 {
+		clientEncodings, err := grpc.ClientSupportedCompressors(ctx)
+
+		if err == nil {
+			enc := pickEncoding(encodings, clientEncodings)
+
+			if enc != "" {
+				err = grpc.SetSendCompressor(ctx, enc)
+			}
+		}
+
+		if err != nil {
+			prv.Warnf("couldn't set up compression: %s", err)
+		}
+	}
+
+	// Any extra headers (like the rate limiting headers) go back as
+	// trailers, since they're decided along with the response.
+	if len(resp.Headers) > 0 {
+		trailer := metadata.MD{}
+
+		for key, values := range resp.Headers {
+			trailer.Append(key, values...)
+		}
+
+		err := grpc.SetTrailer(ctx, trailer)
+
+		if err != nil {
+			prv.Warnf("couldn't set trailers: %s", err)
+		}
+	}
+
+	// A gRPC response can only be malformed in one way: by missing its
+	// payload.
+	if resp.Malformed != "" {
+		prv.noteMalformed(resp.Malformed)
+		resp.Data = withoutPayload(resp.Data)
+	}
+
+	err = grpcConnectionFault(ctx, prv, &resp)
+
+	if err != nil {
+		return nil, err
+	}
+
 	return &resp, nil
 }
@@ -18,8 +18,10 @@
 package faces
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -37,20 +39,41 @@ func NewBaseHTTPServer(provider *BaseProvider) *BaseHTTPServer {
 	bsrv.mux = http.NewServeMux()
 	bsrv.mux.HandleFunc("/", bsrv.handleRequest)
 
+	provider.RegisterHealthHandlers(bsrv.mux)
+	provider.RegisterAdminHandlers(bsrv.mux)
+
 	provider.SetHTTPGetHandler(bsrv.defaultGetHandler)
 
 	return bsrv
 }
 
+// Start runs the server until it fails or shuts down gracefully; a
+// graceful shutdown returns nil.
 func (bsrv *BaseHTTPServer) Start(addr string) error {
-	bsrv.provider.Infof("Starting server on %s", addr)
+	prv := bsrv.provider
+	prv.Infof("Starting server on %s", addr)
 
 	httpServer := &http.Server{
 		Addr:    addr,
 		Handler: bsrv.mux,
 	}
 
-	return httpServer.ListenAndServe()
+	return prv.serveUntilShutdown(
+		httpServer.ListenAndServe,
+		func() {
+			// Closing connections after each response while we drain
+			// moves keepalive clients on to other replicas.
+			httpServer.SetKeepAlivesEnabled(false)
+		},
+		func(ctx context.Context) {
+			err := httpServer.Shutdown(ctx)
+
+			if err != nil {
+				prv.Warnf("couldn't shut down cleanly, closing anyway: %s", err)
+				httpServer.Close()
+			}
+		},
+	)
 }
 
 func (bsrv *BaseHTTPServer) handleRequest(w http.ResponseWriter, r *http.Request) {
@@ -123,13 +146,40 @@ func (bsrv *BaseHTTPServer) defaultGetHandler(w http.ResponseWriter, r *http.Req
 		userAgent:  userAgent,
 		row:        row,
 		col:        col,
+		fault:      r.Header.Get(prv.faultHeaderName),
+		clientAddr: clientAddress(r),
 	}
 
+	ctx, cancel := prv.requestContext(r)
+	defer cancel()
+
+	prvReq.ctx = ctx
+
 	resp := prv.HandleRequest(start, prvReq)
 
 	bsrv.StandardResponse(w, r, resp)
 }
 
+// clientAddress returns the address of the client making a request, without
+// the port: the first X-Forwarded-For entry if there is one, otherwise the
+// address of the peer.
+func clientAddress(r *http.Request) string {
+	forwarded := r.Header.Get("X-Forwarded-For")
+
+	if forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(first)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
 func (bsrv *BaseHTTPServer) StandardError(w http.ResponseWriter, r *http.Request, statusCode int, responseBody string) {
 	bsrv.standardHeaders(w, r, statusCode, "text/plain")
 	w.Write([]byte(responseBody))
@@ -144,12 +194,22 @@ func (bsrv *BaseHTTPServer) StandardResponse(w http.ResponseWriter, r *http.Requ
 		"status":         response.StatusCode,
 	}
 
-	if response.Data != nil {
-		for key, value := range response.Data {
+	data := response.Data
+
+	if response.Malformed == MalformedMissingField {
+		data = withoutPayload(data)
+	}
+
+	if data != nil {
+		for key, value := range data {
 			rdict[key] = value
 		}
 	}
 
+	if response.Padding != "" {
+		rdict["padding"] = response.Padding
+	}
+
 	responseBodyBytes, err := json.Marshal(rdict)
 	responseType := "application/json"
 
@@ -160,6 +220,43 @@ func (bsrv *BaseHTTPServer) StandardResponse(w http.ResponseWriter, r *http.Requ
 		responseType = "text/plain"
 	}
 
+	for key, values := range response.Headers {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+
+	if response.ConnectionFault != "" {
+		if bsrv.httpConnectionFault(w, r, response.StatusCode, responseType, responseBodyBytes, response.ConnectionFault) {
+			return
+		}
+	}
+
+	if response.Malformed != "" {
+		bsrv.writeMalformed(w, r, response.StatusCode, responseType, responseBodyBytes, response.Malformed)
+		return
+	}
+
+	// Compress the response if the client accepts any of our encodings.
+	encodings := bsrv.provider.compressionEncodings()
+
+	if len(encodings) > 0 {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		enc := pickEncoding(encodings, strings.Split(r.Header.Get("Accept-Encoding"), ","))
+
+		if enc != "" {
+			compressed, err := compressBody(enc, responseBodyBytes)
+
+			if err != nil {
+				bsrv.provider.Warnf("couldn't compress response with %s: %s", enc, err)
+			} else {
+				w.Header().Set("Content-Encoding", enc)
+				responseBodyBytes = compressed
+			}
+		}
+	}
+
 	bsrv.standardHeaders(w, r, response.StatusCode, responseType)
 	w.Write([]byte(responseBodyBytes))
 }
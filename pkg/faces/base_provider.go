@@ -18,6 +18,7 @@
 package faces
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"hash/crc32"
@@ -25,14 +26,16 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
-	"strconv"
+	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/BuoyantIO/faces-demo/v2/pkg/utils"
 	"github.com/BuoyantIO/faces-demo/v2/pkg/whisper"
 	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
 )
 
 // Glowing stuff
@@ -54,6 +57,32 @@ type BaseRequestStatus struct {
 	message     string
 	statusCode  int
 	delayMs     int
+
+	// errorFraction is the error fraction that was in effect for this
+	// request, which might have come from a subrequest profile.
+	errorFraction int
+
+	// rateLimit is what the rate limiter had to say about this request, if
+	// anything.
+	rateLimit *utils.TokenBucketResult
+
+	// connectionFault is the connection fault to inflict on the response,
+	// if any.
+	connectionFault string
+
+	// malformed is the kind of malformed response to send, if any.
+	malformed string
+
+	// resources is the CPU and memory that the request should use up.
+	resources resourceCost
+
+	// paddingBytes is how much padding to add to the response.
+	paddingBytes        int
+	paddingCompressible bool
+
+	// grpcCode is the gRPC code to fail with, if it was picked from
+	// GRPC_ERROR_CODES.
+	grpcCode codes.Code
 }
 
 func (rstat *BaseRequestStatus) IsErrored() bool {
@@ -86,6 +115,11 @@ type ProviderRequest struct {
 	userAgent  string
 	row        int
 	col        int
+	fault      string // Raw value of the fault header, if any
+	clientAddr string // Client address, without the port
+
+	// ctx carries the caller's deadline; see deadline.go.
+	ctx context.Context
 }
 
 func (prvReq *ProviderRequest) InfoStr() string {
@@ -95,6 +129,22 @@ func (prvReq *ProviderRequest) InfoStr() string {
 type ProviderResponse struct {
 	StatusCode int
 	Data       map[string]interface{}
+	Headers    http.Header // Extra headers (or gRPC trailers) to send back
+
+	// ConnectionFault, if set, tells the server to break the connection
+	// instead of sending the response normally.
+	ConnectionFault string
+
+	// Malformed, if set, tells the server to send a malformed response
+	// (only if the response is otherwise successful).
+	Malformed string
+
+	// GRPCCode, if set, is the gRPC code that a gRPC server should use for
+	// an error response, rather than working one out from StatusCode.
+	GRPCCode codes.Code
+
+	// Padding, if set, goes into the response to make it bigger.
+	Padding string
 }
 
 func ProviderResponseNotImplemented() ProviderResponse {
@@ -180,15 +230,45 @@ type ProviderUpdater func(*BaseProvider)
 type ProviderHook func(*BaseProvider, *ProviderRequest, *BaseRequestStatus) bool
 
 type BaseProvider struct {
-	Name               string // Name of this kind of provider
-	Key                string // Descriptive string for this provider instance
-	lock               sync.Mutex
-	logger             *slog.Logger
-	delayBuckets       []int
-	errorFraction      int
-	latchFraction      int
-	maxRate            float64
+	Name           string // Name of this kind of provider
+	Key            string // Descriptive string for this provider instance
+	lock           sync.Mutex
+	logger         *slog.Logger
+	delayBuckets   []int
+	latencySpec    string
+	latency        *LatencyDistribution
+	profiles       map[string]*FaultProfile
+	rules          *FaultRuleSet
+	errorFraction  int
+	errorCodes     *codeMix
+	grpcErrorCodes *codeMix
+	latchFraction  int
+	maxRate        float64
+	rateLimitMode  string
+	rateLimitBurst int
+	rateLimitKey   string
+	maxInFlight    int
+	maxQueue       int
+	queueTimeoutMs int
+
+	resetFraction      int
+	hangFraction       int
+	stallFraction      int
+	trickleFraction    int
+	trickleBytesPerSec int
+	malformedFraction  int
+	malformedModes     []string
+
+	concurrencyLimitMode string
+	adaptiveLimit        *utils.AdaptiveLimit
+	adaptiveMinLimit     int
+	adaptiveMaxLimit     int
+	adaptiveTargetMs     int
+
 	userHeaderName     string
+	faultHeaderName    string
+	deadlineHeaderName string
+	allowFaultHeader   bool
 	hostIP             string
 	hostName           string
 	debugEnabled       bool
@@ -200,11 +280,54 @@ type BaseProvider struct {
 	preHooks  []ProviderHook
 	postHooks []ProviderHook
 
+	// faultsEnabled is false for providers (like the GUI) that only call
+	// SetupBasicsFromEnvironment, and so have no fault settings.
+	faultsEnabled      bool
+	configFile         string
+	configPollInterval time.Duration
+	configHandler      ConfigHandler
+	configOrigins      map[string]string
+	configRaw          []byte
+	configError        string
+
+	drainPeriod     time.Duration
+	shutdownTimeout time.Duration
+	draining        atomic.Bool
+	shutdownHooks   []func(ctx context.Context)
+
+	markedNotReady        bool
+	notReadyUntil         time.Time
+	readinessFailFraction int
+
 	requestsTotal   *prometheus.CounterVec
 	requestDuration *prometheus.HistogramVec
+	requestsShed    *prometheus.CounterVec
+
+	connectionFaults   *prometheus.CounterVec
+	malformedResponses *prometheus.CounterVec
+
+	latched       bool
+	latchClock    latchClock
+	latchRecovery *LatchRecovery
+	warmup        *Warmup
+	warmupStart   time.Time
+
+	cpuBurnMs          int
+	cpuBurnIterations  int
+	memoryPerRequestKB int
+	memoryLeakKBPerSec int
+	leaked             [][]byte
+	leakedBytes        int
+	cpuBurnSeconds     *prometheus.CounterVec
+
+	paddingSpec         string
+	padding             *LatencyDistribution
+	paddingCompressible bool
+	compression         []string
 
-	latched         bool
 	rateCounter     *utils.RateCounter
+	tokenBucket     *utils.TokenBucket
+	limiter         *utils.ConcurrencyLimiter
 	lastRequestTime time.Time
 
 	whisper              *whisper.Whisper
@@ -218,6 +341,8 @@ func (bprv *BaseProvider) SetupBasicsFromEnvironment() {
 	bprv.debugEnabled = utils.BoolFromEnv("DEBUG_ENABLED", false)
 
 	bprv.userHeaderName = utils.StringFromEnv("USER_HEADER_NAME", "X-Faces-User")
+	bprv.faultHeaderName = utils.StringFromEnv("FAULT_HEADER_NAME", "X-Faces-Fault")
+	bprv.deadlineHeaderName = utils.StringFromEnv("DEADLINE_HEADER_NAME", "X-Faces-Deadline")
 	bprv.hostIP = utils.StringFromEnv("HOST_IP", utils.StringFromEnv("HOSTNAME", "unknown"))
 
 	hostname, err := os.Hostname()
@@ -228,6 +353,28 @@ func (bprv *BaseProvider) SetupBasicsFromEnvironment() {
 
 	bprv.hostName = utils.StringFromEnv("HOSTNAME", hostname)
 
+	bprv.configFile = utils.Env.String("CONFIG_FILE", "")
+	bprv.configPollInterval = utils.Env.Duration("CONFIG_POLL_INTERVAL", 5*time.Second)
+
+	if bprv.configPollInterval <= 0 {
+		utils.Env.Check("CONFIG_POLL_INTERVAL", fmt.Errorf("must be positive, not %s", bprv.configPollInterval))
+	}
+
+	bprv.drainPeriod = utils.Env.Duration("DRAIN_PERIOD", 5*time.Second)
+	bprv.shutdownTimeout = utils.Env.Duration("SHUTDOWN_TIMEOUT", 30*time.Second)
+
+	if bprv.drainPeriod < 0 {
+		utils.Env.Check("DRAIN_PERIOD", fmt.Errorf("can't be negative, not %s", bprv.drainPeriod))
+	}
+
+	if bprv.shutdownTimeout <= 0 {
+		utils.Env.Check("SHUTDOWN_TIMEOUT", fmt.Errorf("must be positive, not %s", bprv.shutdownTimeout))
+	}
+
+	notReadyFor := utils.Env.Duration("NOT_READY_FOR", 0)
+	bprv.notReadyUntil = time.Now().Add(notReadyFor)
+	bprv.readinessFailFraction = utils.Env.Percentage("READINESS_FAIL_FRACTION", 0)
+
 	bprv.requestsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "requests_total",
@@ -251,40 +398,151 @@ func (bprv *BaseProvider) SetupBasicsFromEnvironment() {
 	bprv.Infof("booted on %s (%s)", bprv.hostName, bprv.hostIP)
 	bprv.Infof("userHeaderName %v", bprv.userHeaderName)
 	bprv.Infof("debug_enabled %v", bprv.debugEnabled)
+	bprv.Infof("not_ready_for %s, readiness_fail_fraction %d", notReadyFor, bprv.readinessFailFraction)
 }
 
 func (bprv *BaseProvider) SetupFromEnvironment() {
 	bprv.SetupBasicsFromEnvironment()
 
-	delayBucketsStr := utils.StringFromEnv("DELAY_BUCKETS", "")
+	bprv.faultsEnabled = true
 
-	if delayBucketsStr != "" {
-		delayBuckets := strings.Split(delayBucketsStr, ",")
-		for _, bucketStr := range delayBuckets {
-			bucket, err := strconv.Atoi(bucketStr)
-			if err == nil {
-				if bucket < 0 {
-					bucket = 0
-				}
+	cfg := LoadFaultEnvConfig(utils.Env)
 
-				bprv.delayBuckets = append(bprv.delayBuckets, bucket)
-			}
-		}
+	bprv.allowFaultHeader = cfg.AllowFaultHeader
+	bprv.adaptiveMinLimit = cfg.AdaptiveMinLimit
+	bprv.adaptiveMaxLimit = cfg.AdaptiveMaxLimit
+	bprv.adaptiveTargetMs = cfg.AdaptiveTargetMs
+
+	bprv.connectionFaults = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "connection_faults_total",
+			Help: "Total number of connection faults injected",
+		},
+		[]string{"provider", "hostname", "fault"},
+	)
+
+	prometheus.MustRegister(bprv.connectionFaults)
+
+	bprv.malformedResponses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "malformed_responses_total",
+			Help: "Total number of malformed responses sent",
+		},
+		[]string{"provider", "hostname", "mode"},
+	)
+
+	prometheus.MustRegister(bprv.malformedResponses)
+
+	for _, subrequest := range []string{"all", "center", "edge"} {
+		prometheus.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name:        "latched",
+				Help:        "Whether the provider is latched into the error state (1) or not (0)",
+				ConstLabels: prometheus.Labels{"provider": bprv.Name, "hostname": bprv.hostName, "subrequest": subrequest},
+			},
+			func() float64 { return bprv.latchedGaugeValue(subrequest) },
+		))
 	}
 
-	bprv.errorFraction = utils.PercentageFromEnv("ERROR_FRACTION", 0)
-	bprv.latchFraction = utils.PercentageFromEnv("LATCH_FRACTION", 0)
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name:        "warmup_factor",
+			Help:        "How much of the warm-up penalty currently applies (1 at startup, 0 once warmed up)",
+			ConstLabels: prometheus.Labels{"provider": bprv.Name, "hostname": bprv.hostName},
+		},
+		bprv.warmupFactor,
+	))
+
+	bprv.cpuBurnSeconds = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cpu_burn_seconds_total",
+			Help: "Total time spent deliberately burning CPU",
+		},
+		[]string{"provider", "hostname"},
+	)
+
+	prometheus.MustRegister(bprv.cpuBurnSeconds)
+
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name:        "leaked_bytes",
+			Help:        "Memory deliberately leaked so far",
+			ConstLabels: prometheus.Labels{"provider": bprv.Name, "hostname": bprv.hostName},
+		},
+		func() float64 {
+			return float64(bprv.LeakedBytes())
+		},
+	))
+
+	bprv.setupConcurrencyLimiter()
 
-	bprv.maxRate = utils.FloatFromEnv("MAX_RATE", 0.0)
+	// If the settings aren't valid, don't go any further: FinishSetup will
+	// refuse to start.
+	err := cfg.Validate()
 
-	if bprv.maxRate >= 0.1 {
-		bprv.rateCounter = utils.NewRateCounter(10)
+	if err != nil {
+		utils.Env.Check("fault settings", err)
+		return
 	}
 
+	// Applying the settings also sets up the latency distribution, the rate
+	// limiter, and the concurrency limiter.
+	bprv.applyFaultSettingsLocked(cfg.FaultSettings)
+
+	// The leak is always running, but it does nothing until
+	// memoryLeakKBPerSec is set.
+	go bprv.leakMemory()
+
 	bprv.Infof("delay_buckets %v", bprv.delayBuckets)
+	bprv.Infof("latency_distribution %v", bprv.latency)
 	bprv.Infof("error_fraction %d", bprv.errorFraction)
+	bprv.Infof("error_codes %s", bprv.errorCodes)
+	bprv.Infof("grpc_error_codes %s", bprv.grpcErrorCodes)
 	bprv.Infof("latch_fraction %d", bprv.latchFraction)
 	bprv.Infof("max_rate %f", bprv.maxRate)
+	bprv.Infof("malformed_fraction %d %v", bprv.malformedFraction, bprv.malformedModes)
+	bprv.Infof("connection faults: reset %d, hang %d, stall %d, trickle %d (%d bytes/sec)", bprv.resetFraction, bprv.hangFraction, bprv.stallFraction, bprv.trickleFraction, bprv.trickleBytesPerSec)
+	bprv.Infof("rate_limit %s, burst %d, key %s", bprv.rateLimitMode, bprv.rateLimitBurst, bprv.rateLimitKey)
+	bprv.Infof("max_in_flight %d, max_queue %d, queue_timeout_ms %d", bprv.maxInFlight, bprv.maxQueue, bprv.queueTimeoutMs)
+	bprv.Infof("concurrency_limit_mode %s", bprv.concurrencyLimitMode)
+	bprv.Infof("latch_recovery %s", bprv.latchRecovery)
+	bprv.Infof("warmup %s", bprv.warmup)
+	bprv.Infof("padding %s (compressible %v), compression %v", bprv.paddingSpec, bprv.paddingCompressible, bprv.compression)
+	bprv.Infof("cpu_burn_ms %d, cpu_burn_iterations %d, memory_per_request_kb %d, memory_leak_kb_per_sec %d", bprv.cpuBurnMs, bprv.cpuBurnIterations, bprv.memoryPerRequestKB, bprv.memoryLeakKBPerSec)
+	bprv.Infof("allow_fault_header %v (%s)", bprv.allowFaultHeader, bprv.faultHeaderName)
+
+	for _, subrequest := range []string{"center", "edge"} {
+		if fp := bprv.profiles[subrequest]; fp != nil {
+			profileJSON, _ := json.Marshal(fp)
+			bprv.Infof("%s_profile %s", subrequest, string(profileJSON))
+		}
+	}
+
+	if cfg.FaultRulesFile != "" {
+		rules, err := LoadFaultRulesFile(cfg.FaultRulesFile)
+
+		if err != nil {
+			utils.Env.Check("FAULT_RULES_FILE", fmt.Errorf("couldn't load fault rules %s: %w", cfg.FaultRulesFile, err))
+		} else {
+			bprv.rules = rules
+			bprv.Infof("fault_rules %s: %d rules", cfg.FaultRulesFile, len(rules.Rules))
+		}
+	}
+
+	if cfg.ScenarioFile != "" {
+		scenario, err := LoadScenarioFile(cfg.ScenarioFile)
+
+		if err != nil {
+			utils.Env.Check("SCENARIO_FILE", fmt.Errorf("couldn't load scenario %s: %w", cfg.ScenarioFile, err))
+		} else {
+			if scenario.Name == "" {
+				scenario.Name = cfg.ScenarioFile
+			}
+
+			bprv.Infof("scenario %s: %d phases, repeat %v, length %s", scenario.Name, len(scenario.Phases), scenario.Repeat, scenario.Length)
+			bprv.AddUpdater(NewScenarioRunner(scenario, nil).Updater)
+		}
+	}
 }
 
 func (bprv *BaseProvider) EnableWhisper(whisperAddr string, name string, nodeNumber int, processNumber int) {
@@ -309,6 +567,10 @@ func (bprv *BaseProvider) EnableWhisper(whisperAddr string, name string, nodeNum
 	bprv.whisperSelfID = crc32.ChecksumIEEE([]byte(localName))
 	bprv.whisper.SetID(bprv.whisperSelfID)
 	bprv.Infof("Whisper enabled: %s", bprv.whisper.String())
+
+	bprv.OnShutdown(func(ctx context.Context) {
+		w.Close()
+	})
 }
 
 func (bprv *BaseProvider) Announce(ok bool, value int) error {
@@ -384,6 +646,7 @@ func (bprv *BaseProvider) SetLogger(logger *slog.Logger) {
 
 func (bprv *BaseProvider) SetDebug(debug bool) {
 	bprv.debugEnabled = debug
+	utils.SetDebugLogging(debug)
 }
 
 func (bprv *BaseProvider) Lock() {
@@ -399,6 +662,10 @@ func (bprv *BaseProvider) IsLatched() bool {
 }
 
 func (bprv *BaseProvider) SetLatched(latched bool) {
+	if latched && !bprv.latched {
+		bprv.latchClock.start(time.Now())
+	}
+
 	bprv.latched = latched
 }
 
@@ -406,6 +673,14 @@ func (bprv *BaseProvider) GetUserHeaderName() string {
 	return bprv.userHeaderName
 }
 
+func (bprv *BaseProvider) GetFaultHeaderName() string {
+	return bprv.faultHeaderName
+}
+
+func (bprv *BaseProvider) GetDeadlineHeaderName() string {
+	return bprv.deadlineHeaderName
+}
+
 func (bprv *BaseProvider) ErrorFraction() int {
 	return bprv.errorFraction
 }
@@ -414,42 +689,59 @@ func (bprv *BaseProvider) SetErrorFraction(fraction int) {
 	bprv.errorFraction = fraction
 }
 
-// CheckUnlatch checks to see if we should unlatch the provider.
+// CheckUnlatch checks to see if we should unlatch the provider, or any of
+// its subrequest profiles, according to the LatchRecovery policy.
 func (bprv *BaseProvider) CheckUnlatch(now time.Time) {
 	bprv.lock.Lock()
 	defer bprv.lock.Unlock()
 
-	if bprv.latched {
-		// How long has it been since our last request?
-		delta := now.Sub(bprv.lastRequestTime)
+	why := bprv.latchRecovery.String()
 
-		if delta.Seconds() > 30 {
-			// It's been thirty full seconds since our last request. If we
-			// were latched into the error state, it's time to come out.
-			bprv.latched = false
+	if bprv.latched && bprv.latchRecovery.ShouldUnlatch(&bprv.latchClock, bprv.lastRequestTime, now) {
+		bprv.SetLatched(false)
+		bprv.Infof("unlatched (%s)", why)
+	}
+
+	for subrequest, fp := range bprv.profiles {
+		if fp.IsLatched() && bprv.latchRecovery.ShouldUnlatch(&fp.latchClock, bprv.lastRequestTime, now) {
+			bprv.unlatchProfileLocked(subrequest, fp, why)
 		}
 	}
 }
 
-// DelayIfNeeded delays if there are delay buckets set.
-func (bprv *BaseProvider) DelayIfNeeded(rstat *BaseRequestStatus) {
+// DelayIfNeeded delays if there are delay buckets set. It gives up early,
+// returning ctx's error, if ctx is done before the delay is over (or before
+// it starts).
+func (bprv *BaseProvider) DelayIfNeeded(ctx context.Context, rstat *BaseRequestStatus) error {
 	if rstat.delayMs > 0 {
-		time.Sleep(time.Duration(rstat.delayMs) * time.Millisecond)
+		timer := time.NewTimer(time.Duration(rstat.delayMs) * time.Millisecond)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
 	}
+
+	return ctx.Err()
 }
 
 // CheckRequestStatus checks the state of the provider and decides whether
 // it's OK to have the request continue, or whether it should be failed for
 // various reasons:
 //
-// - If maxRate is set, then we first check the rate counter to see if we
-//   need to fail due to rate limiting.
+// - If maxRate is set, then we first check the rate limiter (average or
+//   token bucket, see ratelimit.go) to see if we need to fail due to rate
+//   limiting.
 // - Otherwise, if we're latched into an error state, then we immediately fail.
 // - Otherwise, if errorFraction is set, then errorFraction% of requests will fail
 //   and, if latchFraction is set, then every error has a latchFraction % chance
 //   to latch the error state.
+//
+// If the request's subrequest (center or edge) has a FaultProfile, its
+// settings override the provider-wide ones.
 
-func (bprv *BaseProvider) CheckRequestStatus() *BaseRequestStatus {
+func (bprv *BaseProvider) CheckRequestStatus(prvReq *ProviderRequest) *BaseRequestStatus {
 	// We need to figure out if we're going to send an error.
 	rstat := &BaseRequestStatus{
 		// It's true that not every provider uses HTTP, but we're going
@@ -459,64 +751,202 @@ func (bprv *BaseProvider) CheckRequestStatus() *BaseRequestStatus {
 
 	start := time.Now()
 
+	// Hold the lock for the whole decision, so that a concurrent update from
+	// the admin API can't leave us looking at half-old, half-new settings.
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
 	// Is a rate limiter active? We do this first because if the rate limiter
 	// trips, we want the service to be unable to do _any_ processing, including
 	// checking for other errors.
 
-	if bprv.rateCounter != nil {
-		bprv.rateCounter.Mark(start)
-		rate := bprv.rateCounter.CurrentRate()
+	bprv.checkRateLimitLocked(prvReq, start, rstat)
+
+	// Work out the settings that apply to this subrequest: the provider-wide
+	// settings, overridden by the subrequest's profile if it has one.
+	errorFraction := bprv.errorFraction
+	latchFraction := bprv.latchFraction
+	latency := bprv.latency
+	latched := bprv.latched
+
+	profile := bprv.profiles[prvReq.subrequest]
+
+	if profile != nil {
+		if profile.ErrorFraction != nil {
+			errorFraction = *profile.ErrorFraction
+		}
+
+		if profile.LatchFraction != nil {
+			latchFraction = *profile.LatchFraction
+		}
 
-		if rate >= bprv.maxRate {
-			// Bzzzt! Rate limited.
-			rstat.ratelimited = true
-			rstat.message = fmt.Sprintf("Rate limited (%.1f RPS > max %.1f RPS)", rate, bprv.maxRate)
+		if profile.latency != nil {
+			latency = profile.latency
 		}
+
+		latched = latched || profile.IsLatched()
+	}
+
+	rstat.errorFraction = errorFraction
+
+	// A fault forced by the fault header trumps all the random stuff below,
+	// and so does a fault from a matching fault rule. A forced fault that
+	// doesn't set a status (say, just a delay) still leaves the latch and
+	// the error fraction to do their thing, though.
+	forced := bprv.requestFault(prvReq)
+
+	if forced == nil {
+		forced = bprv.rules.Evaluate(prvReq)
+	}
+
+	forcedStatus := (forced != nil) && (forced.Latch || (forced.StatusCode >= 400))
+
+	if forced != nil {
+		bprv.Debugf("%s forced fault %+v", forced.source, *forced)
 	}
 
 	// OK, if rate limiting didn't get us, we might still have an error.
 	if !rstat.ratelimited {
-		// If we've gotten latched into an error state, we're definitely sending
-		// an error.
+		if forcedStatus {
+			if forced.Latch {
+				bprv.latchLocked(profile)
 
-		if bprv.IsLatched() {
+				rstat.latched = true
+				rstat.errored = true
+				rstat.message = "Latched into error state"
+				rstat.statusCode = 599
+			}
+
+			if forced.StatusCode >= 400 {
+				rstat.errored = true
+				rstat.statusCode = forced.StatusCode
+
+				if rstat.message == "" {
+					rstat.message = fmt.Sprintf("%s error! (forced %03d by %s)", bprv.Name, forced.StatusCode, forced.source)
+				}
+			}
+		} else if latched && bprv.latchFailsLocked(profile, start) {
+			// If we've gotten latched into an error state, we're sending an
+			// error (unless the latch is recovering, and we got lucky).
 			rstat.latched = true
 			rstat.errored = true
 			rstat.message = "Latched into error state"
 			rstat.statusCode = 599
-		} else if bprv.errorFraction > 0 {
+		} else if errorFraction > 0 {
 			// Not latched, but there's a chance of an error here too.
-			if rand.Intn(100) <= bprv.errorFraction {
+			if rand.Intn(100) <= errorFraction {
 				bprv.Debugf("error fraction triggered")
 
 				// Yup. Error.
 				rstat.errored = true
 				rstat.message = "" // No message, the provider will fill this in.
-				rstat.statusCode = 500
+				rstat.statusCode = bprv.errorCodes.pick(http.StatusInternalServerError)
 
-				// We might get latched here, too.
-				if bprv.latchFraction > 0 && rand.Intn(100) <= bprv.latchFraction {
-					bprv.SetLatched(true)
+				if bprv.grpcErrorCodes != nil {
+					rstat.grpcCode = codes.Code(bprv.grpcErrorCodes.pick(int(codes.Internal)))
+				}
+
+				// We might get latched here, too. If this subrequest has its
+				// own profile, it gets latched on its own.
+				if latchFraction > 0 && rand.Intn(100) <= latchFraction {
+					bprv.latchLocked(profile)
 
 					rstat.latched = true
 					rstat.message = "Latched into error state"
 					rstat.statusCode = 599
+					rstat.grpcCode = codes.OK
 				}
 			}
 		}
 	}
 
-	if len(bprv.delayBuckets) > 0 {
-		delayMs := bprv.delayBuckets[rand.Intn(len(bprv.delayBuckets))]
-		rstat.delayMs = delayMs
+	// Connection faults are independent of everything else, except that a
+	// rate-limited request doesn't get one.
+	if !rstat.ratelimited {
+		if forced != nil {
+			rstat.connectionFault = forced.Connection
+		} else {
+			rstat.connectionFault = bprv.pickConnectionFaultLocked()
+		}
+	}
+
+	// Malformed responses have to look successful, so errors don't get them.
+	if !rstat.ratelimited && !rstat.errored {
+		if forced != nil {
+			rstat.malformed = forced.Malformed
+		} else {
+			rstat.malformed = bprv.pickMalformedLocked()
+		}
+	}
+
+	// A forced status comes with exactly the forced delay; otherwise, any
+	// forced delay goes on top of the usual one.
+	if forcedStatus {
+		rstat.delayMs = forced.DelayMs
+	} else {
+		if latency != nil {
+			rstat.delayMs = latency.SampleMs()
+		}
+
+		if forced != nil {
+			rstat.delayMs += forced.DelayMs
+		}
+	}
+
+	// Resource faults and padding apply to everything that gets past the
+	// rate limiter.
+	if !rstat.ratelimited {
+		rstat.resources = bprv.resourceCostLocked()
+
+		if bprv.padding != nil {
+			rstat.paddingBytes = bprv.padding.Sample()
+			rstat.paddingCompressible = bprv.paddingCompressible
+		}
+	}
+
+	// A replica that's still warming up is slower, and maybe flakier, than
+	// usual. (A warm-up error never gets a malformed response, since only
+	// successes do.) Forced faults are meant to be exact, so they skip this.
+	if forced == nil {
+		bprv.applyWarmupLocked(start, rstat)
 	}
 
 	return rstat
 }
 
+// latchLocked latches the provider into the error state. If the request's
+// subrequest has its own profile, only that profile is latched. The caller
+// must hold the provider lock.
+func (bprv *BaseProvider) latchLocked(profile *FaultProfile) {
+	if profile != nil {
+		if !profile.IsLatched() {
+			profile.latchClock.start(time.Now())
+		}
+
+		profileLatched := true
+		profile.Latched = &profileLatched
+	} else {
+		bprv.SetLatched(true)
+	}
+}
+
 func (bprv *BaseProvider) HandleRequest(start time.Time, prvReq *ProviderRequest) ProviderResponse {
 	resp := ProviderResponseEmpty()
 
+	// Before anything else, we need a concurrency slot. If we can't get one,
+	// the request gets shed without any further ado.
+	if bprv.limiter != nil {
+		err := bprv.limiter.Acquire(prvReq.Context())
+
+		if err != nil {
+			resp = bprv.shedRequest(prvReq, err)
+			bprv.recordRequest(start, resp)
+			return resp
+		}
+
+		defer bprv.releaseConcurrencySlot(time.Now())
+	}
+
 	bprv.CheckUnlatch(start)
 
 	if bprv.updaters != nil {
@@ -525,7 +955,7 @@ func (bprv *BaseProvider) HandleRequest(start time.Time, prvReq *ProviderRequest
 		}
 	}
 
-	rstat := bprv.CheckRequestStatus()
+	rstat := bprv.CheckRequestStatus(prvReq)
 
 	if bprv.whisper != nil {
 		succeeded := !(rstat.IsErrored() || rstat.IsRateLimited())
@@ -548,9 +978,21 @@ func (bprv *BaseProvider) HandleRequest(start time.Time, prvReq *ProviderRequest
 		}
 	}
 
-	bprv.DelayIfNeeded(rstat)
+	// Use up CPU and memory before the delay, so that the memory is held
+	// for the whole request.
+	held := bprv.consumeResources(rstat.resources)
+	defer runtime.KeepAlive(held)
+
+	err := bprv.DelayIfNeeded(prvReq.Context(), rstat)
+
+	if err != nil {
+		msg := fmt.Sprintf("%s gave up: %s", bprv.Name, err)
+
+		bprv.Debugf("DEADLINE(%s) => %s", prvReq.InfoStr(), msg)
 
-	if rstat.IsRateLimited() {
+		resp.StatusCode = http.StatusGatewayTimeout
+		resp.AddError(msg)
+	} else if rstat.IsRateLimited() {
 		bprv.Debugf("RATELIMIT(%s) => %s", prvReq.InfoStr(), rstat.Message())
 
 		resp.StatusCode = http.StatusTooManyRequests
@@ -559,12 +1001,13 @@ func (bprv *BaseProvider) HandleRequest(start time.Time, prvReq *ProviderRequest
 		msg := rstat.Message()
 
 		if msg == "" {
-			msg = fmt.Sprintf("%s error! (error fraction %d%%)", bprv.Name, bprv.errorFraction)
+			msg = fmt.Sprintf("%s error! (error fraction %d%%)", bprv.Name, rstat.errorFraction)
 		}
 
 		bprv.Debugf("ERROR(%s) => %d, %s", prvReq.InfoStr(), rstat.StatusCode(), msg)
 
 		resp.StatusCode = rstat.StatusCode()
+		resp.GRPCCode = rstat.grpcCode
 		resp.AddError(msg)
 	} else {
 		resp = bprv.providerGetHandler(prvReq)
@@ -583,6 +1026,17 @@ func (bprv *BaseProvider) HandleRequest(start time.Time, prvReq *ProviderRequest
 		}
 	}
 
+	if headers := rateLimitHeaders(rstat); headers != nil {
+		resp.Headers = headers
+	}
+
+	resp.ConnectionFault = rstat.connectionFault
+	resp.Padding = makePadding(rstat.paddingBytes, rstat.paddingCompressible)
+
+	if resp.StatusCode == http.StatusOK {
+		resp.Malformed = rstat.malformed
+	}
+
 	if bprv.postHooks != nil {
 		for _, hook := range bprv.postHooks {
 			if !hook(bprv, prvReq, rstat) {
@@ -591,13 +1045,21 @@ func (bprv *BaseProvider) HandleRequest(start time.Time, prvReq *ProviderRequest
 		}
 	}
 
+	bprv.recordRequest(start, resp)
+
+	return resp
+}
+
+// recordRequest notes that a request has finished, for metrics and for
+// CheckUnlatch.
+func (bprv *BaseProvider) recordRequest(start time.Time, resp ProviderResponse) {
 	end := time.Now()
 	delta := end.Sub(start)
 
+	bprv.lock.Lock()
 	bprv.lastRequestTime = end
+	bprv.lock.Unlock()
 
 	bprv.requestsTotal.WithLabelValues(bprv.Name, bprv.hostName, bprv.Key, fmt.Sprintf("%03d", resp.StatusCode)).Inc()
 	bprv.requestDuration.WithLabelValues(bprv.Name, bprv.hostName, bprv.Key).Observe(delta.Seconds())
-
-	return resp
 }
@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"testing"
+)
+
+// A forced fault that's only a delay mustn't get a latched provider out of
+// failing, but a forced status still wins.
+func TestForcedDelayKeepsLatch(t *testing.T) {
+	bprv := newTestProvider()
+	bprv.allowFaultHeader = true
+	bprv.SetLatched(true)
+
+	rstat := bprv.CheckRequestStatus(&ProviderRequest{fault: "delay=200ms"})
+
+	if rstat.statusCode != 599 {
+		t.Errorf("delay-only fault: got status %d, want 599", rstat.statusCode)
+	}
+
+	if rstat.delayMs != 200 {
+		t.Errorf("delay-only fault: got delay %dms, want 200ms", rstat.delayMs)
+	}
+
+	rstat = bprv.CheckRequestStatus(&ProviderRequest{fault: "status=503;delay=100ms"})
+
+	if rstat.statusCode != 503 {
+		t.Errorf("forced status: got status %d, want 503", rstat.statusCode)
+	}
+
+	if rstat.delayMs != 100 {
+		t.Errorf("forced status: got delay %dms, want 100ms", rstat.delayMs)
+	}
+}
+
+// /admin/ready shows the configured readiness, and never rolls the dice for
+// readiness faults; actual readiness checks do.
+func TestReadinessStatusIgnoresFailFraction(t *testing.T) {
+	bprv := newTestProvider()
+	bprv.readinessFailFraction = 100
+
+	rs := bprv.ReadinessStatus()
+
+	if !rs.Ready || rs.FailFraction != 100 {
+		t.Errorf("ReadinessStatus: got %+v, want ready with failFraction 100", rs)
+	}
+
+	ready, reason := bprv.Readiness()
+
+	if ready || reason != "readiness fault" {
+		t.Errorf("Readiness: got %v, '%s', want a readiness fault", ready, reason)
+	}
+}
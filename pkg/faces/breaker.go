@@ -0,0 +1,401 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BuoyantIO/faces-demo/v2/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Face can put a circuit breaker in front of each backend. A breaker starts
+// out closed, letting every call through, and opens when the backend looks
+// broken:
+//
+//   - BREAKER_CONSECUTIVE_FAILURES failures in a row, or
+//   - a failure rate of at least BREAKER_FAILURE_RATE percent, once there
+//     have been at least BREAKER_MIN_CALLS (default 10) calls in the
+//     current BREAKER_WINDOW (default 10s).
+//
+// Setting either threshold turns the breakers on. A failure is any 5xx,
+// including timeouts and garbage responses.
+//
+// While a breaker is open, calls fail fast with a 503 (so the cell gets
+// the usual fallback smiley or color) without ever reaching the backend,
+// and aren't retried. After BREAKER_COOLDOWN (default 10s), the breaker
+// goes half-open and lets a single probe call through: if that works, the
+// breaker closes again, and if not, it opens for another cooldown.
+//
+// These are also the breakerConsecutiveFailures, breakerFailureRate,
+// breakerMinCalls, breakerWindow, and breakerCooldown settings in the face
+// section of a config file. Turning the breakers off closes them.
+
+// Circuit breaker states.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+var allBreakerStates = []string{BreakerClosed, BreakerOpen, BreakerHalfOpen}
+
+// breakerConfig is what every circuit breaker works from.
+type breakerConfig struct {
+	consecutiveFailures int
+	failureRate         int
+	minCalls            int
+	window              time.Duration
+	cooldown            time.Duration
+}
+
+// enabled returns true if the breakers are turned on at all.
+func (config *breakerConfig) enabled() bool {
+	return (config.consecutiveFailures > 0) || (config.failureRate > 0)
+}
+
+// validate checks breaker settings from the face section of a config file.
+func (config *breakerConfig) validate() error {
+	if config.consecutiveFailures < 0 {
+		return fmt.Errorf("face: breakerConsecutiveFailures can't be negative, not %d", config.consecutiveFailures)
+	}
+
+	if (config.failureRate < 0) || (config.failureRate > 100) {
+		return fmt.Errorf("face: breakerFailureRate must be between 0 and 100, not %d", config.failureRate)
+	}
+
+	if config.minCalls < 1 {
+		return fmt.Errorf("face: breakerMinCalls must be at least 1, not %d", config.minCalls)
+	}
+
+	if config.window <= 0 {
+		return fmt.Errorf("face: breakerWindow must be positive, not %s", config.window)
+	}
+
+	if config.cooldown <= 0 {
+		return fmt.Errorf("face: breakerCooldown must be positive, not %s", config.cooldown)
+	}
+
+	return nil
+}
+
+// circuitBreaker is the circuit breaker for a single backend.
+type circuitBreaker struct {
+	lock     sync.Mutex
+	backend  string
+	config   *breakerConfig
+	onChange func(backend string, from string, to string)
+
+	state       string
+	openedAt    time.Time
+	probing     bool
+	consecutive int
+	windowStart time.Time
+	calls       int
+	failures    int
+}
+
+func newCircuitBreaker(backend string, config *breakerConfig, onChange func(backend string, from string, to string)) *circuitBreaker {
+	return &circuitBreaker{
+		backend:  backend,
+		config:   config,
+		onChange: onChange,
+		state:    BreakerClosed,
+	}
+}
+
+// State returns the breaker's current state.
+func (cb *circuitBreaker) State() string {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	return cb.state
+}
+
+// setConfig switches the breaker to a new config. A breaker that's been
+// turned off closes, and one that's still on starts a fresh window.
+func (cb *circuitBreaker) setConfig(now time.Time, config *breakerConfig) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	cb.config = config
+
+	if !config.enabled() || (cb.state == BreakerClosed) {
+		cb.setStateLocked(now, BreakerClosed)
+	}
+}
+
+// setStateLocked changes state. The caller must hold the breaker lock.
+func (cb *circuitBreaker) setStateLocked(now time.Time, state string) {
+	from := cb.state
+	cb.state = state
+
+	switch state {
+	case BreakerOpen:
+		cb.openedAt = now
+
+	case BreakerClosed:
+		cb.consecutive = 0
+		cb.windowStart = now
+		cb.calls = 0
+		cb.failures = 0
+	}
+
+	cb.probing = false
+
+	if cb.onChange != nil {
+		cb.onChange(cb.backend, from, state)
+	}
+}
+
+// allow decides whether a call can go ahead. If it can, probe says whether
+// it's the half-open probe.
+func (cb *circuitBreaker) allow(now time.Time) (ok bool, probe bool) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	if !cb.config.enabled() {
+		return true, false
+	}
+
+	if (cb.state == BreakerOpen) && (now.Sub(cb.openedAt) >= cb.config.cooldown) {
+		cb.setStateLocked(now, BreakerHalfOpen)
+	}
+
+	switch cb.state {
+	case BreakerClosed:
+		return true, false
+
+	case BreakerHalfOpen:
+		if cb.probing {
+			return false, false
+		}
+
+		cb.probing = true
+		return true, true
+	}
+
+	return false, false
+}
+
+// record records how a call went. Calls that started while the breaker was
+// closed, but finished after it opened, don't count.
+func (cb *circuitBreaker) record(now time.Time, probe bool, failed bool) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	if !cb.config.enabled() {
+		return
+	}
+
+	if probe {
+		if failed {
+			cb.setStateLocked(now, BreakerOpen)
+		} else {
+			cb.setStateLocked(now, BreakerClosed)
+		}
+
+		return
+	}
+
+	if cb.state != BreakerClosed {
+		return
+	}
+
+	if now.Sub(cb.windowStart) >= cb.config.window {
+		cb.windowStart = now
+		cb.calls = 0
+		cb.failures = 0
+	}
+
+	cb.calls++
+
+	if !failed {
+		cb.consecutive = 0
+		return
+	}
+
+	cb.failures++
+	cb.consecutive++
+
+	cfg := cb.config
+
+	if (cfg.consecutiveFailures > 0) && (cb.consecutive >= cfg.consecutiveFailures) {
+		cb.setStateLocked(now, BreakerOpen)
+		return
+	}
+
+	if (cfg.failureRate > 0) && (cb.calls >= cfg.minCalls) && (cb.failures*100 >= cfg.failureRate*cb.calls) {
+		cb.setStateLocked(now, BreakerOpen)
+	}
+}
+
+// setupBreakersFromEnvironment reads the circuit breaker settings, and sets
+// up a breaker for each backend. The breakers let everything through while
+// they're turned off.
+func (fprv *FaceProvider) setupBreakersFromEnvironment() {
+	config := &breakerConfig{
+		consecutiveFailures: utils.Env.Int("BREAKER_CONSECUTIVE_FAILURES", 0),
+		failureRate:         utils.Env.Percentage("BREAKER_FAILURE_RATE", 0),
+		minCalls:            utils.Env.Int("BREAKER_MIN_CALLS", 10),
+		window:              utils.Env.Duration("BREAKER_WINDOW", 10*time.Second),
+		cooldown:            utils.Env.Duration("BREAKER_COOLDOWN", 10*time.Second),
+	}
+
+	if config.consecutiveFailures < 0 {
+		utils.Env.Check("BREAKER_CONSECUTIVE_FAILURES", fmt.Errorf("can't be negative, not %d", config.consecutiveFailures))
+	}
+
+	if config.minCalls < 1 {
+		utils.Env.Check("BREAKER_MIN_CALLS", fmt.Errorf("must be at least 1, not %d", config.minCalls))
+	}
+
+	if config.window <= 0 {
+		utils.Env.Check("BREAKER_WINDOW", fmt.Errorf("must be positive, not %s", config.window))
+	}
+
+	if config.cooldown <= 0 {
+		utils.Env.Check("BREAKER_COOLDOWN", fmt.Errorf("must be positive, not %s", config.cooldown))
+	}
+
+	fprv.breakerConfig = config
+
+	fprv.breakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "circuit_breaker_state",
+			Help: "1 for the current state of the circuit breaker for each backend, 0 for the others",
+		},
+		[]string{"provider", "hostname", "backend", "state"},
+	)
+
+	fprv.breakerRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_rejected_total",
+			Help: "Total number of calls to each backend that failed fast because its circuit breaker was open",
+		},
+		[]string{"provider", "hostname", "backend"},
+	)
+
+	prometheus.MustRegister(fprv.breakerState)
+	prometheus.MustRegister(fprv.breakerRejected)
+
+	fprv.breakers = map[string]*circuitBreaker{}
+
+	for _, backend := range []string{"smiley", "color"} {
+		fprv.breakers[backend] = newCircuitBreaker(backend, config, fprv.breakerChanged)
+		fprv.setBreakerStateGauge(backend, BreakerClosed)
+	}
+
+	if !config.enabled() {
+		fprv.Infof("Face: circuit breakers off")
+		return
+	}
+
+	fprv.Infof("Face: breakerConsecutiveFailures %d, breakerFailureRate %d%% (min %d calls per %s), breakerCooldown %s",
+		config.consecutiveFailures, config.failureRate, config.minCalls, config.window, config.cooldown)
+}
+
+// breakerSettings returns the current circuit breaker config, which can
+// change at runtime.
+func (fprv *FaceProvider) breakerSettings() *breakerConfig {
+	fprv.Lock()
+	defer fprv.Unlock()
+
+	return fprv.breakerConfig
+}
+
+// setBreakerConfigLocked switches every breaker to a new config. The caller
+// must hold the provider lock.
+func (fprv *FaceProvider) setBreakerConfigLocked(config *breakerConfig) {
+	fprv.breakerConfig = config
+
+	for _, cb := range fprv.breakers {
+		cb.setConfig(time.Now(), config)
+	}
+}
+
+// breakerChanged is called whenever a breaker changes state.
+func (fprv *FaceProvider) breakerChanged(backend string, from string, to string) {
+	if from == to {
+		return
+	}
+
+	fprv.Infof("%s circuit breaker: %s -> %s", backend, from, to)
+	fprv.setBreakerStateGauge(backend, to)
+}
+
+func (fprv *FaceProvider) setBreakerStateGauge(backend string, state string) {
+	for _, s := range allBreakerStates {
+		value := 0.0
+
+		if s == state {
+			value = 1.0
+		}
+
+		fprv.breakerState.WithLabelValues(fprv.Name, fprv.hostName, backend, s).Set(value)
+	}
+}
+
+// withBreaker makes a call to backend through its circuit breaker, if
+// there is one.
+func (fprv *FaceProvider) withBreaker(backend string, call func() *FaceResponse) *FaceResponse {
+	cb := fprv.breakers[backend]
+
+	if cb == nil {
+		return call()
+	}
+
+	ok, probe := cb.allow(time.Now())
+
+	if !ok {
+		fprv.breakerRejected.WithLabelValues(fprv.Name, fprv.hostName, backend).Inc()
+
+		return &FaceResponse{
+			statusCode:  http.StatusServiceUnavailable,
+			breakerOpen: true,
+			data:        fmt.Sprintf("circuit breaker for %s is %s", backend, cb.State()),
+		}
+	}
+
+	resp := call()
+
+	cb.record(time.Now(), probe, resp.statusCode >= 500)
+
+	return resp
+}
+
+// breakerStates returns the state of each backend's circuit breaker, or
+// nil if they're off.
+func (fprv *FaceProvider) breakerStates() map[string]string {
+	config := fprv.breakerSettings()
+
+	if (config == nil) || !config.enabled() {
+		return nil
+	}
+
+	states := map[string]string{}
+
+	for backend, cb := range fprv.breakers {
+		states[backend] = cb.State()
+	}
+
+	return states
+}
@@ -0,0 +1,194 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"testing"
+	"time"
+)
+
+func testBreaker(config breakerConfig) (*circuitBreaker, *[]string) {
+	changes := []string{}
+
+	cb := newCircuitBreaker("smiley", &config, func(backend string, from string, to string) {
+		if from != to {
+			changes = append(changes, from+" -> "+to)
+		}
+	})
+
+	return cb, &changes
+}
+
+// Consecutive failures open the breaker; after the cooldown, one probe gets
+// through, and its result decides what happens next.
+func TestCircuitBreakerConsecutiveFailures(t *testing.T) {
+	cb, changes := testBreaker(breakerConfig{
+		consecutiveFailures: 3,
+		minCalls:            10,
+		window:              10 * time.Second,
+		cooldown:            5 * time.Second,
+	})
+
+	now := time.Unix(1000, 0)
+
+	// A success resets the run of failures.
+	for _, failed := range []bool{true, true, false, true, true} {
+		if ok, probe := cb.allow(now); !ok || probe {
+			t.Fatalf("closed breaker: got ok %v, probe %v", ok, probe)
+		}
+
+		cb.record(now, false, failed)
+	}
+
+	if cb.State() != BreakerClosed {
+		t.Fatalf("after two failures in a row: got %s, want closed", cb.State())
+	}
+
+	cb.record(now, false, true)
+
+	if cb.State() != BreakerOpen {
+		t.Fatalf("after three failures in a row: got %s, want open", cb.State())
+	}
+
+	if ok, _ := cb.allow(now.Add(4 * time.Second)); ok {
+		t.Errorf("open breaker let a call through before the cooldown")
+	}
+
+	// After the cooldown, exactly one probe goes through.
+	now = now.Add(5 * time.Second)
+
+	if ok, probe := cb.allow(now); !ok || !probe {
+		t.Fatalf("after the cooldown: got ok %v, probe %v; want a probe", ok, probe)
+	}
+
+	if ok, _ := cb.allow(now); ok {
+		t.Errorf("half-open breaker let a second call through")
+	}
+
+	// A failed probe opens it again...
+	cb.record(now, true, true)
+
+	if cb.State() != BreakerOpen {
+		t.Fatalf("after a failed probe: got %s, want open", cb.State())
+	}
+
+	// ...and a successful one closes it.
+	now = now.Add(5 * time.Second)
+	_, probe := cb.allow(now)
+	cb.record(now, probe, false)
+
+	if cb.State() != BreakerClosed {
+		t.Fatalf("after a successful probe: got %s, want closed", cb.State())
+	}
+
+	want := []string{
+		"closed -> open",
+		"open -> half-open",
+		"half-open -> open",
+		"open -> half-open",
+		"half-open -> closed",
+	}
+
+	if len(*changes) != len(want) {
+		t.Fatalf("state changes: got %v, want %v", *changes, want)
+	}
+
+	for i := range want {
+		if (*changes)[i] != want[i] {
+			t.Errorf("state change %d: got %s, want %s", i, (*changes)[i], want[i])
+		}
+	}
+}
+
+// The failure rate only counts once there are enough calls in the window,
+// and the window starts over when it runs out.
+func TestCircuitBreakerFailureRate(t *testing.T) {
+	cb, _ := testBreaker(breakerConfig{
+		failureRate: 50,
+		minCalls:    4,
+		window:      10 * time.Second,
+		cooldown:    5 * time.Second,
+	})
+
+	now := time.Unix(1000, 0)
+
+	cb.record(now, false, true)
+	cb.record(now, false, false)
+	cb.record(now, false, true)
+
+	if cb.State() != BreakerClosed {
+		t.Fatalf("below minCalls: got %s, want closed", cb.State())
+	}
+
+	// A new window forgets the old failures.
+	now = now.Add(10 * time.Second)
+
+	cb.record(now, false, false)
+	cb.record(now, false, false)
+	cb.record(now, false, false)
+	cb.record(now, false, true)
+
+	if cb.State() != BreakerClosed {
+		t.Fatalf("25%% failures: got %s, want closed", cb.State())
+	}
+
+	cb.record(now, false, true)
+
+	if cb.State() != BreakerClosed {
+		t.Fatalf("2 of 5 failed: got %s, want closed", cb.State())
+	}
+
+	cb.record(now, false, true)
+
+	if cb.State() != BreakerOpen {
+		t.Fatalf("3 of 6 failed: got %s, want open", cb.State())
+	}
+}
+
+// A breaker that's turned off lets everything through, and turning it off
+// closes it.
+func TestCircuitBreakerSetConfig(t *testing.T) {
+	cb, _ := testBreaker(breakerConfig{
+		consecutiveFailures: 1,
+		minCalls:            10,
+		window:              10 * time.Second,
+		cooldown:            time.Minute,
+	})
+
+	now := time.Unix(1000, 0)
+
+	cb.record(now, false, true)
+
+	if cb.State() != BreakerOpen {
+		t.Fatalf("after a failure: got %s, want open", cb.State())
+	}
+
+	cb.setConfig(now, &breakerConfig{minCalls: 10, window: 10 * time.Second, cooldown: time.Minute})
+
+	if cb.State() != BreakerClosed {
+		t.Fatalf("after turning it off: got %s, want closed", cb.State())
+	}
+
+	for i := 0; i < 10; i++ {
+		cb.record(now, false, true)
+
+		if ok, _ := cb.allow(now); !ok {
+			t.Fatalf("breaker that's off refused a call")
+		}
+	}
+}
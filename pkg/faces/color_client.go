@@ -0,0 +1,282 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/BuoyantIO/faces-demo/v2/pkg/color"
+	"github.com/BuoyantIO/faces-demo/v2/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+// Face talks to color over a single long-lived gRPC connection (well, a
+// single ClientConn, which may have a connection to each color endpoint),
+// shared by every request, the way a real gRPC client would:
+//
+//   - GRPC_LB_POLICY picks the balancing policy: pick_first (the default)
+//     sends everything to one endpoint, and round_robin spreads requests
+//     across every address that DNS returns for COLOR_SERVICE (which
+//     needs a headless Service in Kubernetes).
+//   - GRPC_KEEPALIVE_TIME, if set, pings the connection when it's been
+//     idle that long (gRPC won't go below 10s), and GRPC_KEEPALIVE_TIMEOUT
+//     (default 20s) is how long to wait for the ping before giving up on
+//     the connection.
+//
+// These are also the grpcLBPolicy, grpcKeepaliveTime, and
+// grpcKeepaliveTimeout settings in the face section of a config file. If
+// any of them, or the color service address, changes, face switches to a
+// new connection right away and closes the old one after
+// colorConnCloseDelay, so that requests still using it get a chance to
+// finish.
+
+// gRPC balancing policies.
+const (
+	GRPCPolicyPickFirst  = "pick_first"
+	GRPCPolicyRoundRobin = "round_robin"
+)
+
+const colorConnCloseDelay = 10 * time.Second
+
+// colorConnStates is every state that a gRPC connection can be in, for the
+// connection state metric.
+var colorConnStates = []connectivity.State{
+	connectivity.Idle,
+	connectivity.Connecting,
+	connectivity.Ready,
+	connectivity.TransientFailure,
+	connectivity.Shutdown,
+}
+
+// grpcSettings is how face sets up its connection to the color service.
+type grpcSettings struct {
+	lbPolicy         string
+	keepaliveTime    time.Duration
+	keepaliveTimeout time.Duration
+}
+
+// validate checks gRPC settings from the face section of a config file.
+func (gs grpcSettings) validate() error {
+	if (gs.lbPolicy != GRPCPolicyPickFirst) && (gs.lbPolicy != GRPCPolicyRoundRobin) {
+		return fmt.Errorf("face: grpcLBPolicy must be %s or %s, not '%s'", GRPCPolicyPickFirst, GRPCPolicyRoundRobin, gs.lbPolicy)
+	}
+
+	if gs.keepaliveTime < 0 {
+		return fmt.Errorf("face: grpcKeepaliveTime can't be negative, not %s", gs.keepaliveTime)
+	}
+
+	if gs.keepaliveTimeout <= 0 {
+		return fmt.Errorf("face: grpcKeepaliveTimeout must be positive, not %s", gs.keepaliveTimeout)
+	}
+
+	return nil
+}
+
+// colorClient is a connection to the color service.
+type colorClient struct {
+	target   string
+	settings grpcSettings
+	conn     *grpc.ClientConn
+	client   color.ColorServiceClient
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// setupColorClientFromEnvironment reads the gRPC client settings and sets
+// up the connection metrics.
+func (fprv *FaceProvider) setupColorClientFromEnvironment() {
+	gs := grpcSettings{
+		lbPolicy:         utils.Env.Choice("GRPC_LB_POLICY", GRPCPolicyPickFirst, GRPCPolicyPickFirst, GRPCPolicyRoundRobin),
+		keepaliveTime:    utils.Env.Duration("GRPC_KEEPALIVE_TIME", 0),
+		keepaliveTimeout: utils.Env.Duration("GRPC_KEEPALIVE_TIMEOUT", 20*time.Second),
+	}
+
+	if gs.keepaliveTime < 0 {
+		utils.Env.Check("GRPC_KEEPALIVE_TIME", fmt.Errorf("can't be negative, not %s", gs.keepaliveTime))
+	}
+
+	if gs.keepaliveTimeout <= 0 {
+		utils.Env.Check("GRPC_KEEPALIVE_TIMEOUT", fmt.Errorf("must be positive, not %s", gs.keepaliveTimeout))
+	}
+
+	fprv.grpcSettings = gs
+
+	fprv.colorConnState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "grpc_client_connection_state",
+			Help: "1 for the current state of the gRPC connection to each target, 0 for the others",
+		},
+		[]string{"provider", "hostname", "target", "state"},
+	)
+
+	fprv.colorConnTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_client_state_transitions_total",
+			Help: "Total number of times the gRPC connection to each target entered each state",
+		},
+		[]string{"provider", "hostname", "target", "state"},
+	)
+
+	prometheus.MustRegister(fprv.colorConnState)
+	prometheus.MustRegister(fprv.colorConnTransitions)
+
+	fprv.OnShutdown(func(ctx context.Context) {
+		fprv.Lock()
+		cc := fprv.colorClient
+		fprv.colorClient = nil
+		fprv.Unlock()
+
+		if cc != nil {
+			fprv.closeColorClient(cc)
+		}
+	})
+
+	fprv.Infof("Face: grpc_lb_policy %s, grpc_keepalive_time %s, grpc_keepalive_timeout %s", gs.lbPolicy, gs.keepaliveTime, gs.keepaliveTimeout)
+}
+
+// colorServiceClient returns the shared client for the color service,
+// connecting (or reconnecting, if the address or the gRPC settings have
+// changed) as needed.
+func (fprv *FaceProvider) colorServiceClient() (color.ColorServiceClient, string, error) {
+	fprv.Lock()
+	defer fprv.Unlock()
+
+	cc := fprv.colorClient
+
+	if (cc != nil) && (cc.target == fprv.colorService) && (cc.settings == fprv.grpcSettings) {
+		return cc.client, cc.target, nil
+	}
+
+	newCC, err := fprv.newColorClient(fprv.colorService, fprv.grpcSettings)
+
+	if err != nil {
+		return nil, fprv.colorService, err
+	}
+
+	fprv.colorClient = newCC
+
+	if cc != nil {
+		if cc.target != newCC.target {
+			fprv.Infof("color service changed from %s to %s", cc.target, newCC.target)
+		} else {
+			fprv.Infof("gRPC settings changed, reconnecting to %s", newCC.target)
+		}
+
+		time.AfterFunc(colorConnCloseDelay, func() { fprv.closeColorClient(cc) })
+	}
+
+	return newCC.client, newCC.target, nil
+}
+
+// newColorClient connects to the color service at target. gRPC's default
+// resolver is DNS, so round_robin balances across every address that target
+// resolves to.
+func (fprv *FaceProvider) newColorClient(target string, gs grpcSettings) (*colorClient, error) {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig": [{"%s": {}}]}`, gs.lbPolicy)),
+	}
+
+	if gs.keepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                gs.keepaliveTime,
+			Timeout:             gs.keepaliveTimeout,
+			PermitWithoutStream: true,
+		}))
+	}
+
+	conn, err := grpc.NewClient(target, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cc := &colorClient{
+		target:   target,
+		settings: gs,
+		conn:     conn,
+		client:   color.NewColorServiceClient(conn),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	go fprv.watchColorConnState(ctx, cc)
+
+	// Connect right away, rather than waiting for the first request.
+	conn.Connect()
+
+	return cc, nil
+}
+
+// watchColorConnState keeps the connection state metrics up to date until
+// ctx is done.
+func (fprv *FaceProvider) watchColorConnState(ctx context.Context, cc *colorClient) {
+	defer close(cc.done)
+
+	for {
+		state := cc.conn.GetState()
+
+		fprv.Debugf("gRPC connection to %s: %s", cc.target, state)
+
+		for _, s := range colorConnStates {
+			value := 0.0
+
+			if s == state {
+				value = 1.0
+			}
+
+			fprv.colorConnState.WithLabelValues(fprv.Name, fprv.hostName, cc.target, s.String()).Set(value)
+		}
+
+		fprv.colorConnTransitions.WithLabelValues(fprv.Name, fprv.hostName, cc.target, state.String()).Inc()
+
+		if !cc.conn.WaitForStateChange(ctx, state) {
+			return
+		}
+	}
+}
+
+// closeColorClient closes a connection to the color service, and drops
+// its connection state metrics (the transition counts stay) unless we've
+// since gone back to the same target.
+func (fprv *FaceProvider) closeColorClient(cc *colorClient) {
+	cc.cancel()
+	<-cc.done
+
+	err := cc.conn.Close()
+
+	if err != nil {
+		fprv.Warnf("couldn't close gRPC connection to %s: %s", cc.target, err)
+	}
+
+	fprv.Lock()
+	current := fprv.colorClient
+	fprv.Unlock()
+
+	if (current == nil) || (current.target != cc.target) {
+		fprv.colorConnState.DeletePartialMatch(prometheus.Labels{"target": cc.target})
+	}
+}
@@ -29,7 +29,7 @@ type ColorProvider struct {
 	colors map[string]string
 }
 
-func NewColorProviderFromEnvironment() *ColorProvider {
+func NewColorProviderFromEnvironment() (*ColorProvider, error) {
 	cprv := &ColorProvider{
 		BaseProvider: BaseProvider{
 			Name: "Color",
@@ -45,10 +45,13 @@ func NewColorProviderFromEnvironment() *ColorProvider {
 
 	cprv.BaseProvider.SetupFromEnvironment()
 
-	// Set the initial colors by hand: we explicitly want to use the
-	// fallback color if anything goes wrong here.
+	// Set the initial colors by hand, since SetColor wants the lock.
 	colorName := utils.StringFromEnv("COLOR", "blue")
-	color, _ := utils.Colors.Lookup(colorName)
+	color, found := utils.Colors.Lookup(colorName)
+
+	if !found {
+		utils.Env.Check("COLOR", fmt.Errorf("unknown color '%s'", colorName))
+	}
 
 	cprv.Infof("Starting with color %s => %s", colorName, color)
 
@@ -58,7 +61,13 @@ func NewColorProviderFromEnvironment() *ColorProvider {
 	// This isn't really ideal.
 	cprv.Key = colorName
 
-	return cprv
+	err := cprv.FinishSetup(cprv)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cprv, nil
 }
 
 func (cprv *ColorProvider) Get(prvReq *ProviderRequest) ProviderResponse {
@@ -116,3 +125,40 @@ func (cprv *ColorProvider) SetColor(which string, color string) (string, error)
 
 	return newColor, nil
 }
+
+// ValidateConfig checks the color section of a config file.
+func (cprv *ColorProvider) ValidateConfig(cfg *ProviderConfig) error {
+	if cfg.Color == nil {
+		return nil
+	}
+
+	return validateCellConfig("color", cfg.Color, utils.Colors.Lookup)
+}
+
+// ApplyConfig applies the color section of a config file.
+func (cprv *ColorProvider) ApplyConfig(cfg *ProviderConfig) {
+	if cfg.Color == nil {
+		return
+	}
+
+	if cfg.Color.Center != "" {
+		cprv.SetColor("center", cfg.Color.Center)
+	}
+
+	if cfg.Color.Edge != "" {
+		cprv.SetColor("edge", cfg.Color.Edge)
+	}
+}
+
+// ConfigSnapshot returns the current colors, for logging config changes.
+func (cprv *ColorProvider) ConfigSnapshot() map[string]interface{} {
+	cprv.Lock()
+	defer cprv.Unlock()
+
+	return map[string]interface{}{
+		"color": CellConfig{
+			Center: cprv.colors["center"],
+			Edge:   cprv.colors["edge"],
+		},
+	}
+}
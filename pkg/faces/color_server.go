@@ -22,12 +22,14 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"time"
 
 	"fmt"
 
 	"github.com/BuoyantIO/faces-demo/v2/pkg/color"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/status"
 )
 
@@ -47,11 +49,21 @@ func NewColorServer(provider *ColorProvider) *colorServer {
 }
 
 func (srv *colorServer) Start(port int) error {
-	var grpcOpts []grpc.ServerOption
+	// By default, gRPC servers hang up on clients that send keepalive
+	// pings more often than every five minutes, so allow face's
+	// GRPC_KEEPALIVE_TIME to go as low as gRPC itself will let it.
+	grpcOpts := []grpc.ServerOption{
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	}
 
 	grpcServer := grpc.NewServer(grpcOpts...)
 	color.RegisterColorServiceServer(grpcServer, srv)
 
+	health := newHealthServer(grpcServer, &srv.provider.BaseProvider, color.ColorService_ServiceDesc.ServiceName)
+
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 
 	if err != nil {
@@ -59,23 +71,50 @@ func (srv *colorServer) Start(port int) error {
 	}
 
 	slog.Info(fmt.Sprintf("listening on %s", listener.Addr()))
-	grpcServer.Serve(listener)
 
-	return nil
+	prv := &srv.provider.BaseProvider
+
+	return prv.serveUntilShutdown(
+		func() error {
+			return grpcServer.Serve(listener)
+		},
+		nil,
+		func(ctx context.Context) {
+			// GracefulStop tells clients to go away, then waits for every
+			// RPC to finish, which might be never (e.g. with a hang
+			// fault), so give up when ctx is done.
+			health.stop()
+
+			stopped := make(chan struct{})
+
+			go func() {
+				grpcServer.GracefulStop()
+				close(stopped)
+			}()
+
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				prv.Warnf("couldn't shut down cleanly, stopping anyway")
+				grpcServer.Stop()
+			}
+		},
+	)
 }
 
 func (srv *colorServer) BuildResponse(resp *ProviderResponse) (*color.ColorResponse, error) {
 	switch resp.StatusCode {
 	case http.StatusOK:
 		return &color.ColorResponse{
-			Color: resp.GetString("color"),
+			Color:   resp.GetString("color"),
+			Padding: resp.Padding,
 		}, nil
 
 	case http.StatusTooManyRequests:
 		return nil, status.Errorf(codes.ResourceExhausted, "rate limited: %s", resp.GetErrors())
 
 	default:
-		return nil, status.Errorf(codes.Internal, "failed to get color: %s", resp.GetErrors())
+		return nil, status.Errorf(grpcCodeForResponse(resp), "failed to get color: %s", resp.GetErrors())
 	}
 }
 
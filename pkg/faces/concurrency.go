@@ -0,0 +1,211 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/BuoyantIO/faces-demo/v2/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Concurrency limit modes. In ConcurrencyLimitFixed mode, the limit is just
+// maxInFlight. The adaptive modes (utils.AdaptiveAIMD and
+// utils.AdaptiveGradient) start with maxInFlight (or 20, if it's not set)
+// and then adjust the limit from the latencies they see.
+const (
+	ConcurrencyLimitFixed = "fixed"
+)
+
+// defaultAdaptiveInitialLimit is where adaptive limits start if maxInFlight
+// isn't set.
+const defaultAdaptiveInitialLimit = 20
+
+// setupConcurrencyLimiter creates the provider's ConcurrencyLimiter, and the
+// metrics that go with it. MAX_IN_FLIGHT limits how many requests the
+// provider will work on at once (0, the default, means no limit); up to
+// MAX_QUEUE more will wait for QUEUE_TIMEOUT_MS, and anything past that is
+// shed with a 503. CONCURRENCY_LIMIT_MODE can switch to an adaptive limit
+// instead.
+func (bprv *BaseProvider) setupConcurrencyLimiter() {
+	bprv.limiter = utils.NewConcurrencyLimiter(0, 0, 0)
+
+	constLabels := prometheus.Labels{"provider": bprv.Name, "hostname": bprv.hostName}
+
+	inFlight := prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name:        "in_flight_requests",
+			Help:        "Number of requests currently being processed",
+			ConstLabels: constLabels,
+		},
+		func() float64 { return float64(bprv.limiter.InFlight()) },
+	)
+
+	queueDepth := prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name:        "queue_depth",
+			Help:        "Number of requests waiting for a concurrency slot",
+			ConstLabels: constLabels,
+		},
+		func() float64 { return float64(bprv.limiter.QueueDepth()) },
+	)
+
+	limit := prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name:        "concurrency_limit",
+			Help:        "Current concurrency limit (0 means unlimited)",
+			ConstLabels: constLabels,
+		},
+		func() float64 { return float64(bprv.limiter.Limit()) },
+	)
+
+	bprv.requestsShed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "requests_shed_total",
+			Help: "Total number of requests rejected by the concurrency limit",
+		},
+		[]string{"provider", "hostname", "reason"},
+	)
+
+	prometheus.MustRegister(inFlight)
+	prometheus.MustRegister(queueDepth)
+	prometheus.MustRegister(limit)
+	prometheus.MustRegister(bprv.requestsShed)
+}
+
+// validateConcurrency checks the concurrency limit settings.
+func validateConcurrency(mode string, maxInFlight int, maxQueue int, queueTimeoutMs int) error {
+	switch mode {
+	case "", ConcurrencyLimitFixed, utils.AdaptiveAIMD, utils.AdaptiveGradient:
+	default:
+		return fmt.Errorf("concurrencyLimitMode must be %s, %s, or %s, not '%s'", ConcurrencyLimitFixed, utils.AdaptiveAIMD, utils.AdaptiveGradient, mode)
+	}
+
+	if maxInFlight < 0 {
+		return fmt.Errorf("maxInFlight must not be negative, not %d", maxInFlight)
+	}
+
+	if maxQueue < 0 {
+		return fmt.Errorf("maxQueue must not be negative, not %d", maxQueue)
+	}
+
+	if queueTimeoutMs < 0 {
+		return fmt.Errorf("queueTimeoutMs must not be negative, not %d", queueTimeoutMs)
+	}
+
+	return nil
+}
+
+// configureConcurrencyLimiterLocked pushes the provider's concurrency
+// settings into its limiter, setting up an AdaptiveLimit if need be. Once an
+// adaptive limit is running, changing maxInFlight doesn't affect it; only
+// changing the mode starts it over. The caller must hold the provider lock.
+func (bprv *BaseProvider) configureConcurrencyLimiterLocked() {
+	if bprv.limiter == nil {
+		return
+	}
+
+	if bprv.concurrencyLimitMode == "" {
+		bprv.concurrencyLimitMode = ConcurrencyLimitFixed
+	}
+
+	limit := bprv.maxInFlight
+
+	if bprv.concurrencyLimitMode == ConcurrencyLimitFixed {
+		bprv.adaptiveLimit = nil
+	} else {
+		if bprv.adaptiveLimit == nil || bprv.adaptiveLimit.Algorithm() != bprv.concurrencyLimitMode {
+			initial := bprv.maxInFlight
+
+			if initial <= 0 {
+				initial = defaultAdaptiveInitialLimit
+			}
+
+			al, err := utils.NewAdaptiveLimit(bprv.concurrencyLimitMode, initial,
+				bprv.adaptiveMinLimit, bprv.adaptiveMaxLimit,
+				time.Duration(bprv.adaptiveTargetMs)*time.Millisecond)
+
+			if err != nil {
+				// The mode and the ADAPTIVE_* settings were validated
+				// already, so this "can't happen".
+				bprv.Warnf("can't use %s concurrency limit, using fixed: %s", bprv.concurrencyLimitMode, err)
+				bprv.concurrencyLimitMode = ConcurrencyLimitFixed
+				bprv.adaptiveLimit = nil
+			} else {
+				bprv.adaptiveLimit = al
+				bprv.Infof("concurrency limit: %s", al)
+			}
+		}
+
+		if bprv.adaptiveLimit != nil {
+			limit = bprv.adaptiveLimit.Limit()
+		}
+	}
+
+	bprv.limiter.Configure(limit, bprv.maxQueue, time.Duration(bprv.queueTimeoutMs)*time.Millisecond)
+}
+
+// releaseConcurrencySlot gives back a slot from the concurrency limiter,
+// first telling the adaptive limit (if any) how long the request took.
+func (bprv *BaseProvider) releaseConcurrencySlot(acquired time.Time) {
+	bprv.lock.Lock()
+	al := bprv.adaptiveLimit
+	bprv.lock.Unlock()
+
+	if al != nil {
+		bprv.limiter.SetLimit(al.OnSample(time.Since(acquired), bprv.limiter.InFlight()))
+	}
+
+	bprv.limiter.Release()
+}
+
+// shedRequest builds the response for a request that couldn't get past the
+// concurrency limit. A request whose caller gave up (or whose deadline
+// passed) while it was queued gets a 504, like one that gives up while
+// it's being delayed.
+func (bprv *BaseProvider) shedRequest(prvReq *ProviderRequest, err error) ProviderResponse {
+	reason := "queue_full"
+	statusCode := http.StatusServiceUnavailable
+	msg := fmt.Sprintf("%s overloaded: %s", bprv.Name, err)
+
+	switch {
+	case errors.Is(err, utils.ErrQueueTimeout):
+		reason = "queue_timeout"
+
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		reason = "gave_up"
+		statusCode = http.StatusGatewayTimeout
+		msg = fmt.Sprintf("%s gave up: %s", bprv.Name, err)
+	}
+
+	bprv.Debugf("SHED(%s) => %s", prvReq.InfoStr(), msg)
+
+	resp := ProviderResponseEmpty()
+	resp.StatusCode = statusCode
+	resp.AddError(msg)
+
+	if bprv.requestsShed != nil {
+		bprv.requestsShed.WithLabelValues(bprv.Name, bprv.hostName, reason).Inc()
+	}
+
+	return resp
+}
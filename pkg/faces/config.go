@@ -0,0 +1,539 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BuoyantIO/faces-demo/v2/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// A ProviderConfig is the contents of a CONFIG_FILE, which can hold the
+// settings for every kind of workload at once, e.g.:
+//
+//	debug: false
+//	faults: { errorFraction: 20, delayBuckets: [0, 50, 100] }
+//	smiley: { center: Grinning, edge: Cursing }
+//	color: { center: blue, edge: green }
+//	face: { smileyService: smiley, colorService: color:80 }
+//	gui: { numRows: 6, numCols: 6 }
+//
+// That way a single ConfigMap can be mounted into every workload: each one
+// uses the sections that make sense for it and ignores the rest. Like a
+// PATCH, anything not mentioned is left alone, so taking a setting out of
+// the file leaves it at its last value.
+type ProviderConfig struct {
+	Debug            *bool               `json:"debug,omitempty" yaml:"debug,omitempty"`
+	AllowFaultHeader *bool               `json:"allowFaultHeader,omitempty" yaml:"allowFaultHeader,omitempty"`
+	Faults           *FaultSettingsPatch `json:"faults,omitempty" yaml:"faults,omitempty"`
+	Rules            *[]*FaultRule       `json:"rules,omitempty" yaml:"rules,omitempty"`
+	Smiley           *CellConfig         `json:"smiley,omitempty" yaml:"smiley,omitempty"`
+	Color            *CellConfig         `json:"color,omitempty" yaml:"color,omitempty"`
+	Face             *FaceConfig         `json:"face,omitempty" yaml:"face,omitempty"`
+	GUI              *GUIConfig          `json:"gui,omitempty" yaml:"gui,omitempty"`
+}
+
+// A CellConfig sets what the smiley or color workload hands back for
+// center and edge cells. Either can be left empty to leave it alone.
+type CellConfig struct {
+	Center string `json:"center,omitempty" yaml:"center,omitempty"`
+	Edge   string `json:"edge,omitempty" yaml:"edge,omitempty"`
+}
+
+// FaceConfig holds the face workload's own settings. Timeouts, backoffs,
+// and the like are durations like "2s", where a timeout of "0s" means no
+// timeout.
+type FaceConfig struct {
+	SmileyService  *string `json:"smileyService,omitempty" yaml:"smileyService,omitempty"`
+	ColorService   *string `json:"colorService,omitempty" yaml:"colorService,omitempty"`
+	SmileyTimeout  *string `json:"smileyTimeout,omitempty" yaml:"smileyTimeout,omitempty"`
+	ColorTimeout   *string `json:"colorTimeout,omitempty" yaml:"colorTimeout,omitempty"`
+	RequestTimeout *string `json:"requestTimeout,omitempty" yaml:"requestTimeout,omitempty"`
+
+	GRPCLBPolicy         *string `json:"grpcLBPolicy,omitempty" yaml:"grpcLBPolicy,omitempty"`
+	GRPCKeepaliveTime    *string `json:"grpcKeepaliveTime,omitempty" yaml:"grpcKeepaliveTime,omitempty"`
+	GRPCKeepaliveTimeout *string `json:"grpcKeepaliveTimeout,omitempty" yaml:"grpcKeepaliveTimeout,omitempty"`
+
+	RetryMaxAttempts   *int      `json:"retryMaxAttempts,omitempty" yaml:"retryMaxAttempts,omitempty"`
+	RetryStatusCodes   *[]int    `json:"retryStatusCodes,omitempty" yaml:"retryStatusCodes,omitempty"`
+	RetryGRPCCodes     *[]string `json:"retryGRPCCodes,omitempty" yaml:"retryGRPCCodes,omitempty"`
+	RetryBackoff       *string   `json:"retryBackoff,omitempty" yaml:"retryBackoff,omitempty"`
+	RetryMaxBackoff    *string   `json:"retryMaxBackoff,omitempty" yaml:"retryMaxBackoff,omitempty"`
+	RetryBudgetPercent *int      `json:"retryBudgetPercent,omitempty" yaml:"retryBudgetPercent,omitempty"`
+
+	BreakerConsecutiveFailures *int    `json:"breakerConsecutiveFailures,omitempty" yaml:"breakerConsecutiveFailures,omitempty"`
+	BreakerFailureRate         *int    `json:"breakerFailureRate,omitempty" yaml:"breakerFailureRate,omitempty"`
+	BreakerMinCalls            *int    `json:"breakerMinCalls,omitempty" yaml:"breakerMinCalls,omitempty"`
+	BreakerWindow              *string `json:"breakerWindow,omitempty" yaml:"breakerWindow,omitempty"`
+	BreakerCooldown            *string `json:"breakerCooldown,omitempty" yaml:"breakerCooldown,omitempty"`
+}
+
+// GUIConfig holds the GUI workload's own settings. Changes show up the next
+// time a browser loads the GUI.
+type GUIConfig struct {
+	FaceService *string `json:"faceService,omitempty" yaml:"faceService,omitempty"`
+	BgColor     *string `json:"bgColor,omitempty" yaml:"bgColor,omitempty"`
+	HideKey     *bool   `json:"hideKey,omitempty" yaml:"hideKey,omitempty"`
+	ShowPods    *bool   `json:"showPods,omitempty" yaml:"showPods,omitempty"`
+	NumRows     *int    `json:"numRows,omitempty" yaml:"numRows,omitempty"`
+	NumCols     *int    `json:"numCols,omitempty" yaml:"numCols,omitempty"`
+	EdgeSize    *int    `json:"edgeSize,omitempty" yaml:"edgeSize,omitempty"`
+	StartActive *bool   `json:"startActive,omitempty" yaml:"startActive,omitempty"`
+}
+
+// A ConfigHandler is how a provider takes care of its own sections of a
+// ProviderConfig; the BaseProvider handles the rest.
+type ConfigHandler interface {
+	// ValidateConfig checks the provider's sections of cfg without
+	// changing anything.
+	ValidateConfig(cfg *ProviderConfig) error
+
+	// ApplyConfig applies the provider's sections of cfg, which have
+	// already been validated.
+	ApplyConfig(cfg *ProviderConfig)
+
+	// ConfigSnapshot returns the provider's current settings, so that we
+	// can log what a new config changed.
+	ConfigSnapshot() map[string]interface{}
+}
+
+// ParseProviderConfig parses a ProviderConfig from YAML or JSON. It only
+// checks the syntax; validation happens when a provider applies it.
+func ParseProviderConfig(raw []byte) (*ProviderConfig, error) {
+	cfg := &ProviderConfig{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+
+	err := decoder.Decode(cfg)
+
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("couldn't parse config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// watchConfigFile loads CONFIG_FILE, if it's set, and then keeps checking
+// it every CONFIG_POLL_INTERVAL (default 5s) so that changes get applied
+// without a restart. Polling the contents (rather than watching for file
+// events) copes with the symlink swaps that Kubernetes uses to update a
+// mounted ConfigMap. If the file can't be loaded at startup, that's an
+// error; after that, a bad file is just logged.
+func (bprv *BaseProvider) watchConfigFile() error {
+	path := bprv.configFile
+
+	if path == "" {
+		return nil
+	}
+
+	interval := bprv.configPollInterval
+
+	bprv.Infof("config_file %s, polling every %s", path, interval)
+
+	err := bprv.reloadConfig(path)
+
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			bprv.reloadConfig(path)
+		}
+	}()
+
+	return nil
+}
+
+// reloadConfig rereads the config file and, if it's changed since last time,
+// validates and applies it. An invalid config is logged and otherwise
+// ignored, leaving the running settings exactly as they were; the error is
+// also returned, for the benefit of startup.
+func (bprv *BaseProvider) reloadConfig(path string) error {
+	raw, err := os.ReadFile(path)
+
+	if err != nil {
+		err = fmt.Errorf("couldn't read config %s: %w", path, err)
+		bprv.configProblem(err.Error())
+		return err
+	}
+
+	if bprv.configRaw != nil && bytes.Equal(raw, bprv.configRaw) {
+		return nil
+	}
+
+	bprv.configRaw = raw
+
+	cfg, err := ParseProviderConfig(raw)
+
+	if err == nil {
+		err = bprv.validateConfig(cfg)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("rejecting config %s, keeping current settings: %w", path, err)
+		bprv.configProblem(err.Error())
+		return err
+	}
+
+	bprv.configError = ""
+
+	changes := bprv.recordChanges(OriginConfigFile, func() {
+		bprv.applyConfig(cfg)
+	})
+
+	if len(changes) == 0 {
+		bprv.Infof("config %s loaded: no changes", path)
+		return nil
+	}
+
+	bprv.Infof("config %s loaded: %d changes", path, len(changes))
+
+	for _, change := range changes {
+		bprv.Infof("config: %s", change)
+	}
+
+	return nil
+}
+
+// configProblem logs a problem with the config file, but only once until
+// something changes, so that a broken file doesn't spam the logs every poll.
+func (bprv *BaseProvider) configProblem(msg string) {
+	if msg != bprv.configError {
+		bprv.Warnf("%s", msg)
+		bprv.configError = msg
+	}
+}
+
+// validateConfig checks every section of cfg that this provider cares
+// about, without changing anything.
+func (bprv *BaseProvider) validateConfig(cfg *ProviderConfig) error {
+	if cfg.Faults != nil && bprv.faultsEnabled {
+		fs := cfg.Faults.Apply(bprv.FaultSettings())
+
+		err := fs.Validate()
+
+		if err != nil {
+			return fmt.Errorf("faults: %w", err)
+		}
+	}
+
+	if cfg.Rules != nil && bprv.faultsEnabled {
+		_, err := ruleSetFromConfig(cfg)
+
+		if err != nil {
+			return fmt.Errorf("rules: %w", err)
+		}
+	}
+
+	if bprv.configHandler != nil {
+		return bprv.configHandler.ValidateConfig(cfg)
+	}
+
+	return nil
+}
+
+// applyConfig applies every section of cfg that this provider cares about.
+// cfg must already have been validated.
+func (bprv *BaseProvider) applyConfig(cfg *ProviderConfig) {
+	if cfg.Debug != nil {
+		bprv.SetDebug(*cfg.Debug)
+	}
+
+	if cfg.AllowFaultHeader != nil {
+		bprv.lock.Lock()
+		bprv.allowFaultHeader = *cfg.AllowFaultHeader
+		bprv.lock.Unlock()
+	}
+
+	if cfg.Faults != nil && bprv.faultsEnabled {
+		_, err := bprv.UpdateFaultSettings(*cfg.Faults)
+
+		if err != nil {
+			// This "can't happen" since we've already validated it.
+			bprv.Warnf("couldn't apply fault settings: %s", err)
+		}
+	}
+
+	if cfg.Rules != nil && bprv.faultsEnabled {
+		rs, _ := ruleSetFromConfig(cfg)
+		bprv.SetFaultRules(rs)
+	}
+
+	if bprv.configHandler != nil {
+		bprv.configHandler.ApplyConfig(cfg)
+	}
+}
+
+// ruleSetFromConfig builds a FaultRuleSet from a config's rules. It copies
+// the rules, since setting them up modifies them.
+func ruleSetFromConfig(cfg *ProviderConfig) (*FaultRuleSet, error) {
+	rs := &FaultRuleSet{}
+
+	for _, rule := range *cfg.Rules {
+		if rule == nil {
+			return nil, fmt.Errorf("empty rule")
+		}
+
+		ruleCopy := *rule
+		rs.Rules = append(rs.Rules, &ruleCopy)
+	}
+
+	err := rs.setup()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+// configSnapshot returns the provider's current settings, flattened into
+// "section.setting" keys.
+func (bprv *BaseProvider) configSnapshot() map[string]string {
+	bprv.lock.Lock()
+
+	snapshot := map[string]interface{}{
+		"debug":            bprv.debugEnabled,
+		"allowFaultHeader": bprv.allowFaultHeader,
+	}
+
+	if bprv.faultsEnabled {
+		rules := []*FaultRule{}
+
+		if bprv.rules != nil {
+			rules = bprv.rules.Rules
+		}
+
+		snapshot["faults"] = bprv.faultSettingsLocked()
+		snapshot["rules"] = rules
+	}
+
+	bprv.lock.Unlock()
+
+	if bprv.configHandler != nil {
+		for key, value := range bprv.configHandler.ConfigSnapshot() {
+			snapshot[key] = value
+		}
+	}
+
+	flat := map[string]string{}
+
+	// Round-tripping through JSON turns everything into maps, slices, and
+	// scalars, which are easy to flatten.
+	raw, err := json.Marshal(snapshot)
+
+	if err != nil {
+		bprv.Warnf("couldn't snapshot config: %s", err)
+		return flat
+	}
+
+	var generic interface{}
+	_ = json.Unmarshal(raw, &generic)
+
+	flattenConfig("", generic, flat)
+
+	return flat
+}
+
+// flattenConfig flattens nested maps into dotted keys. Anything that isn't
+// a map (including lists) becomes a single JSON value.
+func flattenConfig(prefix string, value interface{}, flat map[string]string) {
+	if m, ok := value.(map[string]interface{}); ok {
+		for key, sub := range m {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+
+			flattenConfig(key, sub, flat)
+		}
+
+		return
+	}
+
+	// Smileys are HTML entities, so don't escape them.
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+
+	flat[prefix] = strings.TrimSpace(buf.String())
+}
+
+// Where the current value of a setting came from, as shown by /config.
+// Anything that hasn't changed since startup came from the environment (or
+// a default), and /config shows which.
+const (
+	OriginStartup    = "startup"
+	OriginConfigFile = "config file"
+	OriginAdminAPI   = "admin API"
+	OriginScenario   = "scenario"
+)
+
+// recordChanges runs change, and then notes origin as the source of every
+// setting that it changed. It returns descriptions of the changes.
+func (bprv *BaseProvider) recordChanges(origin string, change func()) []string {
+	before := bprv.configSnapshot()
+	change()
+	after := bprv.configSnapshot()
+
+	changed, descriptions := diffConfigSnapshots(before, after)
+
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	if bprv.configOrigins == nil {
+		bprv.configOrigins = map[string]string{}
+	}
+
+	for _, key := range changed {
+		bprv.configOrigins[key] = origin
+	}
+
+	return descriptions
+}
+
+// diffConfigSnapshots works out which settings changed between two
+// snapshots, returning their keys and a description of each change, both
+// in order.
+func diffConfigSnapshots(before, after map[string]string) ([]string, []string) {
+	keys := map[string]bool{}
+
+	for key := range before {
+		keys[key] = true
+	}
+
+	for key := range after {
+		keys[key] = true
+	}
+
+	sortedKeys := make([]string, 0, len(keys))
+
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+
+	sort.Strings(sortedKeys)
+
+	changed := []string{}
+	descriptions := []string{}
+
+	for _, key := range sortedKeys {
+		oldValue, hadOld := before[key]
+		newValue, hasNew := after[key]
+
+		switch {
+		case !hadOld:
+			descriptions = append(descriptions, fmt.Sprintf("%s: (unset) => %s", key, newValue))
+		case !hasNew:
+			descriptions = append(descriptions, fmt.Sprintf("%s: %s => (unset)", key, oldValue))
+		case oldValue != newValue:
+			descriptions = append(descriptions, fmt.Sprintf("%s: %s => %s", key, oldValue, newValue))
+		default:
+			continue
+		}
+
+		changed = append(changed, key)
+	}
+
+	return changed, descriptions
+}
+
+// A configValue is one entry in the "current" section of /config.
+type configValue struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// HandleConfig implements /config, which shows every setting along with
+// where its value came from: "environment" lists everything read from the
+// environment at startup (and whether it was set or defaulted), and
+// "current" lists the settings that can change at runtime, with whatever
+// last changed each one.
+func (bprv *BaseProvider) HandleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		adminError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+		return
+	}
+
+	snapshot := bprv.configSnapshot()
+
+	keys := make([]string, 0, len(snapshot))
+
+	for key := range snapshot {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	current := make([]configValue, 0, len(keys))
+
+	bprv.lock.Lock()
+
+	for _, key := range keys {
+		origin, ok := bprv.configOrigins[key]
+
+		if !ok {
+			origin = OriginStartup
+		}
+
+		current = append(current, configValue{
+			Name:   key,
+			Value:  snapshot[key],
+			Source: origin,
+		})
+	}
+
+	bprv.lock.Unlock()
+
+	adminJSON(w, http.StatusOK, map[string]interface{}{
+		"environment": utils.Env.All(),
+		"current":     current,
+	})
+}
+
+// validateCellConfig checks a CellConfig against a lookup table (utils.Smileys
+// or utils.Colors).
+func validateCellConfig(name string, cc *CellConfig, lookup func(string) (string, bool)) error {
+	for _, value := range []string{cc.Center, cc.Edge} {
+		if value == "" {
+			continue
+		}
+
+		if _, found := lookup(value); !found {
+			return fmt.Errorf("%s: unknown %s '%s'", name, name, value)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Connection faults break a request below the level of HTTP status codes
+// and gRPC errors:
+//
+//   - reset slams the TCP connection shut with an RST
+//   - hang never responds at all (until the client gives up)
+//   - stall sends the response headers, but never the body
+//   - trickle sends the response body a few bytes at a time
+//
+// For gRPC, a reset fails only the one RPC with Unavailable (the
+// connection is shared by all of the client's RPCs, so resetting it would
+// fail every one of them), a stall sends the response headers and then
+// hangs, and a trickle just delays the response for as long as trickling it
+// would have taken, since a unary response is a single message.
+const (
+	ConnectionFaultReset   = "reset"
+	ConnectionFaultHang    = "hang"
+	ConnectionFaultStall   = "stall"
+	ConnectionFaultTrickle = "trickle"
+)
+
+// defaultTrickleBytesPerSec is how fast a trickle goes when
+// trickleBytesPerSec is left at zero.
+const defaultTrickleBytesPerSec = 100
+
+// validConnectionFault returns true if name is a connection fault.
+func validConnectionFault(name string) bool {
+	switch name {
+	case ConnectionFaultReset, ConnectionFaultHang, ConnectionFaultStall, ConnectionFaultTrickle:
+		return true
+	}
+
+	return false
+}
+
+// pickConnectionFaultLocked rolls the dice for each kind of connection
+// fault in turn, returning the first one that comes up (or "" for none).
+// The caller must hold the provider lock.
+func (bprv *BaseProvider) pickConnectionFaultLocked() string {
+	faults := []struct {
+		name     string
+		fraction int
+	}{
+		{ConnectionFaultReset, bprv.resetFraction},
+		{ConnectionFaultHang, bprv.hangFraction},
+		{ConnectionFaultStall, bprv.stallFraction},
+		{ConnectionFaultTrickle, bprv.trickleFraction},
+	}
+
+	for _, fault := range faults {
+		if fault.fraction > 0 && rand.Intn(100) < fault.fraction {
+			return fault.name
+		}
+	}
+
+	return ""
+}
+
+// trickleDelay is how long to wait between bytes when trickling.
+func (bprv *BaseProvider) trickleDelay() time.Duration {
+	bprv.lock.Lock()
+	bytesPerSec := bprv.trickleBytesPerSec
+	bprv.lock.Unlock()
+
+	if bytesPerSec <= 0 {
+		bytesPerSec = 1
+	}
+
+	return time.Second / time.Duration(bytesPerSec)
+}
+
+// noteConnectionFault counts a connection fault in the metrics.
+func (bprv *BaseProvider) noteConnectionFault(fault string) {
+	bprv.Debugf("connection fault: %s", fault)
+
+	if bprv.connectionFaults != nil {
+		bprv.connectionFaults.WithLabelValues(bprv.Name, bprv.hostName, fault).Inc()
+	}
+}
+
+// httpConnectionFault carries out a connection fault for an HTTP response.
+// It returns false if it couldn't (e.g. the connection can't be hijacked),
+// in which case the caller should just send the response normally.
+func (bsrv *BaseHTTPServer) httpConnectionFault(w http.ResponseWriter, r *http.Request, statusCode int, contentType string, body []byte, fault string) bool {
+	prv := bsrv.provider
+
+	switch fault {
+	case ConnectionFaultReset:
+		hijacker, ok := w.(http.Hijacker)
+
+		if !ok {
+			prv.Warnf("can't reset connection: response isn't hijackable")
+			return false
+		}
+
+		conn, _, err := hijacker.Hijack()
+
+		if err != nil {
+			prv.Warnf("can't reset connection: %s", err)
+			return false
+		}
+
+		prv.noteConnectionFault(fault)
+		resetConn(conn)
+
+	case ConnectionFaultHang:
+		prv.noteConnectionFault(fault)
+		<-r.Context().Done()
+
+	case ConnectionFaultStall:
+		prv.noteConnectionFault(fault)
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		bsrv.standardHeaders(w, r, statusCode, contentType)
+
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+
+		<-r.Context().Done()
+
+	case ConnectionFaultTrickle:
+		prv.noteConnectionFault(fault)
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		bsrv.standardHeaders(w, r, statusCode, contentType)
+
+		flusher, _ := w.(http.Flusher)
+		delay := prv.trickleDelay()
+
+		for i := range body {
+			select {
+			case <-r.Context().Done():
+				return true
+
+			case <-time.After(delay):
+			}
+
+			w.Write(body[i : i+1])
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+	default:
+		return false
+	}
+
+	return true
+}
+
+// grpcConnectionFault carries out a connection fault for a gRPC request.
+// It returns a non-nil error if the request should fail with it; a nil
+// error means the response should go out as usual (perhaps late).
+func grpcConnectionFault(ctx context.Context, prv *BaseProvider, resp *ProviderResponse) error {
+	fault := resp.ConnectionFault
+
+	switch fault {
+	case ConnectionFaultReset:
+		// All of a client's RPCs share one HTTP/2 connection, so slamming
+		// it shut would take every other request in flight down with this
+		// one. Fail just this one, the way a reset stream would.
+		prv.noteConnectionFault(fault)
+		return status.Errorf(codes.Unavailable, "connection reset")
+
+	case ConnectionFaultHang:
+		prv.noteConnectionFault(fault)
+		<-ctx.Done()
+		return status.FromContextError(ctx.Err()).Err()
+
+	case ConnectionFaultStall:
+		prv.noteConnectionFault(fault)
+
+		err := grpc.SendHeader(ctx, metadata.MD{})
+
+		if err != nil {
+			prv.Warnf("couldn't send headers: %s", err)
+		}
+
+		<-ctx.Done()
+		return status.FromContextError(ctx.Err()).Err()
+
+	case ConnectionFaultTrickle:
+		prv.noteConnectionFault(fault)
+
+		// We can't trickle a single message, so just take as long as it
+		// would have taken.
+		size := len(fmt.Sprintf("%v", resp.Data))
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+
+		case <-time.After(time.Duration(size) * prv.trickleDelay()):
+		}
+	}
+
+	return nil
+}
+
+// resetConn closes a connection with an RST rather than a FIN, if it's TCP.
+func resetConn(conn net.Conn) {
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetLinger(0)
+	}
+
+	conn.Close()
+}
@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Deadlines travel with requests. Over gRPC that's just the context
+// deadline, which gRPC sends along for us. Over HTTP, it's the
+// X-Faces-Deadline header (or whatever DEADLINE_HEADER_NAME says), which
+// holds the time the caller is still willing to wait, like the grpc-timeout
+// header: either a duration like "1500ms" or "2s", or a plain number of
+// milliseconds. That way clocks don't need to agree.
+//
+// Every workload honors the deadline: a request that runs out of time
+// (or whose caller gives up) while it's being delayed stops waiting right
+// away and fails with a 504 (DeadlineExceeded for gRPC). The face workload
+// also passes whatever is left of its deadline on to smiley and color.
+
+// parseDeadlineBudget parses the value of a deadline header.
+func parseDeadlineBudget(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+
+	ms, err := strconv.Atoi(value)
+
+	if err == nil {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+
+	budget, err := time.ParseDuration(value)
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid deadline '%s'", value)
+	}
+
+	return budget, nil
+}
+
+// formatDeadlineBudget formats the time left until deadline for a deadline
+// header.
+func formatDeadlineBudget(deadline time.Time) string {
+	remaining := time.Until(deadline).Milliseconds()
+
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return fmt.Sprintf("%dms", remaining)
+}
+
+// requestContext returns a context for an incoming HTTP request, with the
+// deadline from the deadline header (if any). The caller must call the
+// returned cancel function when the request is done.
+func (bprv *BaseProvider) requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	value := r.Header.Get(bprv.deadlineHeaderName)
+
+	if value == "" {
+		return context.WithCancel(r.Context())
+	}
+
+	budget, err := parseDeadlineBudget(value)
+
+	if err != nil {
+		bprv.Warnf("ignoring %s: %s", bprv.deadlineHeaderName, err)
+		return context.WithCancel(r.Context())
+	}
+
+	return context.WithTimeout(r.Context(), budget)
+}
+
+// Context returns the request's context, which carries its deadline and is
+// done if the caller gives up.
+func (prvReq *ProviderRequest) Context() context.Context {
+	if prvReq.ctx == nil {
+		return context.Background()
+	}
+
+	return prvReq.ctx
+}
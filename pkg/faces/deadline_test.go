@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDeadlineBudget(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"1500", 1500 * time.Millisecond},
+		{" 250 ", 250 * time.Millisecond},
+		{"0", 0},
+		{"1500ms", 1500 * time.Millisecond},
+		{"2s", 2 * time.Second},
+		{"1m30s", 90 * time.Second},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDeadlineBudget(tt.value)
+
+		if err != nil {
+			t.Errorf("%q: %s", tt.value, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "soon", "2 s", "1.5"} {
+		_, err := parseDeadlineBudget(value)
+
+		if err == nil {
+			t.Errorf("%q should be rejected", value)
+		}
+	}
+}
+
+func TestFormatDeadlineBudget(t *testing.T) {
+	if got := formatDeadlineBudget(time.Now().Add(-time.Second)); got != "0ms" {
+		t.Errorf("past deadline: got %s, want 0ms", got)
+	}
+
+	budget, err := parseDeadlineBudget(formatDeadlineBudget(time.Now().Add(time.Minute)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if budget <= 59*time.Second || budget > time.Minute {
+		t.Errorf("round trip: got %s, want about 1m", budget)
+	}
+}
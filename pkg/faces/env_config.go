@@ -0,0 +1,183 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BuoyantIO/faces-demo/v2/pkg/utils"
+)
+
+// FaultEnvConfig is everything that a provider which injects faults reads
+// from the environment at startup. Most of it is just the initial
+// FaultSettings, so it's validated exactly the same way as a PUT to
+// /admin/faults.
+type FaultEnvConfig struct {
+	FaultSettings
+
+	AllowFaultHeader bool
+	AdaptiveMinLimit int
+	AdaptiveMaxLimit int
+	AdaptiveTargetMs int
+	FaultRulesFile   string
+	ScenarioFile     string
+}
+
+// LoadFaultEnvConfig reads a FaultEnvConfig from env. Values that don't
+// parse are recorded as errors in env; call Validate to check the rest.
+func LoadFaultEnvConfig(env *utils.Settings) FaultEnvConfig {
+	cfg := FaultEnvConfig{}
+
+	cfg.DelayBuckets = env.IntList("DELAY_BUCKETS", []int{})
+	cfg.LatencyDistribution = env.String("LATENCY_DISTRIBUTION", "")
+	cfg.ErrorFraction = env.Percentage("ERROR_FRACTION", 0)
+	cfg.ErrorCodes = env.String("ERROR_CODES", "")
+	cfg.GRPCErrorCodes = env.String("GRPC_ERROR_CODES", "")
+	cfg.LatchFraction = env.Percentage("LATCH_FRACTION", 0)
+	cfg.LatchRecovery = env.String("LATCH_RECOVERY", defaultLatchRecovery.String())
+	cfg.Warmup = env.String("WARMUP", "")
+
+	cfg.CPUBurnMs = env.Int("CPU_BURN_MS", 0)
+	cfg.CPUBurnIterations = env.Int("CPU_BURN_ITERATIONS", 0)
+	cfg.MemoryPerRequestKB = env.Int("MEMORY_PER_REQUEST_KB", 0)
+	cfg.MemoryLeakKBPerSec = env.Int("MEMORY_LEAK_KB_PER_SEC", 0)
+
+	cfg.Padding = env.String("PADDING", "")
+	cfg.PaddingCompressible = env.Bool("PADDING_COMPRESSIBLE", false)
+	cfg.Compression = env.String("COMPRESSION", "")
+
+	cfg.MaxRate = env.Float("MAX_RATE", 0.0)
+	cfg.RateLimitMode = env.Choice("RATE_LIMIT_MODE", RateLimitAverage, RateLimitAverage, RateLimitTokenBucket)
+	cfg.RateLimitBurst = env.Int("RATE_LIMIT_BURST", 0)
+	cfg.RateLimitKey = env.Choice("RATE_LIMIT_KEY", RateLimitKeyGlobal, RateLimitKeyGlobal, RateLimitKeyUser, RateLimitKeyClient)
+
+	cfg.MaxInFlight = env.Int("MAX_IN_FLIGHT", 0)
+	cfg.MaxQueue = env.Int("MAX_QUEUE", 0)
+	cfg.QueueTimeoutMs = env.Int("QUEUE_TIMEOUT_MS", 1000)
+	cfg.ConcurrencyLimitMode = env.Choice("CONCURRENCY_LIMIT_MODE", ConcurrencyLimitFixed, ConcurrencyLimitFixed, utils.AdaptiveAIMD, utils.AdaptiveGradient)
+	cfg.AdaptiveMinLimit = env.Int("ADAPTIVE_MIN_LIMIT", 1)
+	cfg.AdaptiveMaxLimit = env.Int("ADAPTIVE_MAX_LIMIT", 200)
+	cfg.AdaptiveTargetMs = env.Int("ADAPTIVE_TARGET_MS", 500)
+
+	cfg.ResetFraction = env.Percentage("RESET_FRACTION", 0)
+	cfg.HangFraction = env.Percentage("HANG_FRACTION", 0)
+	cfg.StallFraction = env.Percentage("STALL_FRACTION", 0)
+	cfg.TrickleFraction = env.Percentage("TRICKLE_FRACTION", 0)
+	cfg.TrickleBytesPerSec = env.Int("TRICKLE_BYTES_PER_SEC", defaultTrickleBytesPerSec)
+
+	cfg.MalformedFraction = env.Percentage("MALFORMED_FRACTION", 0)
+	cfg.MalformedModes = env.StringList("MALFORMED_MODES", []string{})
+
+	// CENTER_* and EDGE_* let center and edge subrequests have their own
+	// fault settings.
+	cfg.Center = faultProfileFromEnvironment(env, "CENTER_")
+	cfg.Edge = faultProfileFromEnvironment(env, "EDGE_")
+
+	cfg.AllowFaultHeader = env.Bool("ALLOW_FAULT_HEADER", false)
+	cfg.FaultRulesFile = env.String("FAULT_RULES_FILE", "")
+	cfg.ScenarioFile = env.String("SCENARIO_FILE", "")
+
+	return cfg
+}
+
+// Validate checks that a FaultEnvConfig makes sense as a whole.
+func (cfg *FaultEnvConfig) Validate() error {
+	err := cfg.FaultSettings.Validate()
+
+	if err != nil {
+		return err
+	}
+
+	if cfg.AdaptiveMinLimit < 1 {
+		return fmt.Errorf("ADAPTIVE_MIN_LIMIT must be at least 1, not %d", cfg.AdaptiveMinLimit)
+	}
+
+	if cfg.AdaptiveMaxLimit < cfg.AdaptiveMinLimit {
+		return fmt.Errorf("ADAPTIVE_MAX_LIMIT (%d) must be at least ADAPTIVE_MIN_LIMIT (%d)", cfg.AdaptiveMaxLimit, cfg.AdaptiveMinLimit)
+	}
+
+	if cfg.AdaptiveTargetMs < 1 {
+		return fmt.Errorf("ADAPTIVE_TARGET_MS must be at least 1, not %d", cfg.AdaptiveTargetMs)
+	}
+
+	return nil
+}
+
+// faultProfileFromEnvironment reads a FaultProfile from environment
+// variables with the given prefix (e.g. EDGE_ERROR_FRACTION). It returns nil
+// if none of them are set.
+func faultProfileFromEnvironment(env *utils.Settings, prefix string) *FaultProfile {
+	fp := &FaultProfile{}
+
+	if env.IsSet(prefix + "ERROR_FRACTION") {
+		errorFraction := env.Percentage(prefix+"ERROR_FRACTION", 0)
+		fp.ErrorFraction = &errorFraction
+	}
+
+	if env.IsSet(prefix + "LATCH_FRACTION") {
+		latchFraction := env.Percentage(prefix+"LATCH_FRACTION", 0)
+		fp.LatchFraction = &latchFraction
+	}
+
+	if env.IsSet(prefix + "DELAY_BUCKETS") {
+		delayBuckets := env.IntList(prefix+"DELAY_BUCKETS", []int{})
+		fp.DelayBuckets = &delayBuckets
+	}
+
+	if env.IsSet(prefix + "LATENCY_DISTRIBUTION") {
+		latencySpec := env.String(prefix+"LATENCY_DISTRIBUTION", "")
+		fp.LatencyDistribution = &latencySpec
+	}
+
+	if fp.IsEmpty() {
+		return nil
+	}
+
+	return fp
+}
+
+// FinishSetup is the last step of creating a provider from the environment.
+// It warns about any variables that look like misspelled settings, fails if
+// any setting was invalid, and then starts watching CONFIG_FILE. handler
+// takes care of the provider's own sections of the config file, and of its
+// own settings in /config; it may be nil if the provider has none. A config
+// file that's invalid at startup is an error too. Since settings read after
+// this never get checked, main has to read its own settings first.
+func (bprv *BaseProvider) FinishSetup(handler ConfigHandler) error {
+	bprv.lock.Lock()
+	bprv.configHandler = handler
+	bprv.lock.Unlock()
+
+	for _, unknown := range utils.Env.Unknown() {
+		bprv.Warnf("unknown setting %s", unknown)
+	}
+
+	err := utils.Env.Err()
+
+	if err != nil {
+		return fmt.Errorf("invalid settings:\n%s", indent(err.Error()))
+	}
+
+	return bprv.watchConfigFile()
+}
+
+// indent indents every line of a multiline error message.
+func indent(msg string) string {
+	return "  " + strings.ReplaceAll(msg, "\n", "\n  ")
+}
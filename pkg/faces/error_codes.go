@@ -0,0 +1,230 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+)
+
+// A codeMix is a weighted mix of status codes, parsed from a spec like
+//
+//	500:50,503:30,504:20
+//
+// which picks 500 half the time, 503 30% of the time, and 504 20% of the
+// time. Weights are relative, so they needn't add up to 100, and a code with
+// no weight gets a weight of 1.
+type codeMix struct {
+	spec    string
+	codes   []int
+	weights []int
+	total   int
+}
+
+// parseCodeMix parses a codeMix spec, using parseCode to turn each code into
+// an int. An empty spec gives a nil codeMix.
+func parseCodeMix(spec string, parseCode func(string) (int, error)) (*codeMix, error) {
+	spec = strings.TrimSpace(spec)
+
+	if spec == "" {
+		return nil, nil
+	}
+
+	mix := &codeMix{spec: spec}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+
+		if part == "" {
+			continue
+		}
+
+		codeStr, weightStr, hasWeight := strings.Cut(part, ":")
+
+		code, err := parseCode(strings.TrimSpace(codeStr))
+
+		if err != nil {
+			return nil, err
+		}
+
+		weight := 1
+
+		if hasWeight {
+			weight, err = strconv.Atoi(strings.TrimSpace(weightStr))
+
+			if err != nil || weight < 0 {
+				return nil, fmt.Errorf("invalid weight '%s' for %s", weightStr, codeStr)
+			}
+		}
+
+		mix.codes = append(mix.codes, code)
+		mix.weights = append(mix.weights, weight)
+		mix.total += weight
+	}
+
+	if mix.total == 0 {
+		return nil, fmt.Errorf("%s: weights add up to zero", spec)
+	}
+
+	return mix, nil
+}
+
+// parseErrorCodes parses a weighted mix of HTTP status codes, as used by
+// ERROR_CODES. Codes must be between 400 and 599.
+func parseErrorCodes(spec string) (*codeMix, error) {
+	return parseCodeMix(spec, func(codeStr string) (int, error) {
+		code, err := strconv.Atoi(codeStr)
+
+		if err != nil || code < 400 || code > 599 {
+			return 0, fmt.Errorf("invalid HTTP error code '%s'", codeStr)
+		}
+
+		return code, nil
+	})
+}
+
+// parseGRPCErrorCodes parses a weighted mix of gRPC status codes, as used by
+// GRPC_ERROR_CODES. Codes can be given by name (e.g. Unavailable or
+// DEADLINE_EXCEEDED) or number, and can't be OK.
+func parseGRPCErrorCodes(spec string) (*codeMix, error) {
+	return parseCodeMix(spec, func(codeStr string) (int, error) {
+		code, ok := parseGRPCCode(codeStr)
+
+		if !ok || code == codes.OK {
+			return 0, fmt.Errorf("invalid gRPC error code '%s'", codeStr)
+		}
+
+		return int(code), nil
+	})
+}
+
+// parseGRPCCode parses a gRPC code by name or number.
+func parseGRPCCode(codeStr string) (codes.Code, bool) {
+	if n, err := strconv.Atoi(codeStr); err == nil {
+		if n < 0 || n > 16 {
+			return 0, false
+		}
+
+		return codes.Code(n), true
+	}
+
+	wanted := strings.ToLower(strings.ReplaceAll(codeStr, "_", ""))
+
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.ToLower(c.String()) == wanted {
+			return c, true
+		}
+	}
+
+	return 0, false
+}
+
+// String returns the spec that the mix was built from.
+func (mix *codeMix) String() string {
+	if mix == nil {
+		return ""
+	}
+
+	return mix.spec
+}
+
+// pick picks a code from the mix. A nil mix always picks defaultCode.
+func (mix *codeMix) pick(defaultCode int) int {
+	if mix == nil {
+		return defaultCode
+	}
+
+	n := rand.Intn(mix.total)
+
+	for i, weight := range mix.weights {
+		if n < weight {
+			return mix.codes[i]
+		}
+
+		n -= weight
+	}
+
+	// Can't happen, since the weights add up to total.
+	return defaultCode
+}
+
+// grpcCodeForResponse works out the gRPC code to fail a response with: the
+// one picked from GRPC_ERROR_CODES, if there is one, otherwise the closest
+// match to its HTTP status.
+func grpcCodeForResponse(resp *ProviderResponse) codes.Code {
+	if resp.GRPCCode != codes.OK {
+		return resp.GRPCCode
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+
+	return codes.Internal
+}
+
+// httpStatusForGRPCCode goes the other way, for the face workload: it works
+// out the HTTP status that best matches a gRPC code from color.
+func httpStatusForGRPCCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.Aborted, codes.AlreadyExists:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestParseCodeMix(t *testing.T) {
+	tests := []struct {
+		spec    string
+		codes   []int
+		weights []int
+	}{
+		{"503", []int{503}, []int{1}},
+		{"500:50,503:30,504:20", []int{500, 503, 504}, []int{50, 30, 20}},
+		{" 500 : 3 , 503 ", []int{500, 503}, []int{3, 1}},
+		{"500:0,503", []int{500, 503}, []int{0, 1}},
+		{"500,,503", []int{500, 503}, []int{1, 1}},
+	}
+
+	for _, tt := range tests {
+		mix, err := parseErrorCodes(tt.spec)
+
+		if err != nil {
+			t.Errorf("%q: %s", tt.spec, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(mix.codes, tt.codes) || !reflect.DeepEqual(mix.weights, tt.weights) {
+			t.Errorf("%q: got codes %v, weights %v; want %v, %v", tt.spec, mix.codes, mix.weights, tt.codes, tt.weights)
+		}
+
+		if mix.String() != strings.TrimSpace(tt.spec) {
+			t.Errorf("%q: String() gave %q", tt.spec, mix.String())
+		}
+	}
+
+	for _, spec := range []string{"abc", "200", "600", "500:abc", "500:-1", "500:0", "500:0,503:0"} {
+		_, err := parseErrorCodes(spec)
+
+		if err == nil {
+			t.Errorf("%q should be rejected", spec)
+		}
+	}
+
+	mix, err := parseErrorCodes("  ")
+
+	if mix != nil || err != nil {
+		t.Errorf("empty spec: got %v, %v; want nil, nil", mix, err)
+	}
+}
+
+func TestParseGRPCErrorCodes(t *testing.T) {
+	mix, err := parseGRPCErrorCodes("Unavailable:3,DEADLINE_EXCEEDED,13")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int{int(codes.Unavailable), int(codes.DeadlineExceeded), int(codes.Internal)}
+
+	if !reflect.DeepEqual(mix.codes, want) {
+		t.Errorf("got codes %v, want %v", mix.codes, want)
+	}
+
+	for _, spec := range []string{"OK", "0", "17", "NotACode"} {
+		_, err := parseGRPCErrorCodes(spec)
+
+		if err == nil {
+			t.Errorf("%q should be rejected", spec)
+		}
+	}
+}
+
+// A code with no weight never gets picked, and a nil mix always picks the
+// default.
+func TestCodeMixPick(t *testing.T) {
+	mix, err := parseErrorCodes("500:0,503:1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if code := mix.pick(599); code != 503 {
+			t.Fatalf("picked %d, want 503", code)
+		}
+	}
+
+	var none *codeMix
+
+	if code := none.pick(599); code != 599 {
+		t.Errorf("nil mix picked %d, want 599", code)
+	}
+}
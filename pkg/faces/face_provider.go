@@ -30,21 +30,45 @@ import (
 
 	"github.com/BuoyantIO/faces-demo/v2/pkg/color"
 	"github.com/BuoyantIO/faces-demo/v2/pkg/utils"
-	grpc "google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 type FaceProvider struct {
 	BaseProvider
-	smileyService string
-	colorService  string
+	smileyService  string
+	colorService   string
+	smileyTimeout  time.Duration
+	colorTimeout   time.Duration
+	requestTimeout time.Duration
+
+	grpcSettings         grpcSettings
+	colorClient          *colorClient
+	colorConnState       *prometheus.GaugeVec
+	colorConnTransitions *prometheus.CounterVec
+
+	retryPolicy          *retryPolicy
+	retryBudgets         map[string]*retryBudget
+	backendAttempts      *prometheus.CounterVec
+	retryBudgetExhausted *prometheus.CounterVec
+
+	breakerConfig   *breakerConfig
+	breakers        map[string]*circuitBreaker
+	breakerState    *prometheus.GaugeVec
+	breakerRejected *prometheus.CounterVec
 }
 
 type FaceResponse struct {
 	statusCode int
+	grpcCode   codes.Code // Set only if a gRPC call failed outright
 	latency    time.Duration
 	data       string
+
+	// breakerOpen is set if the call never happened because its circuit
+	// breaker was open.
+	breakerOpen bool
 }
 
 func mapStatus(name string, statusCode int) string {
@@ -54,6 +78,11 @@ func mapStatus(name string, statusCode int) string {
 		fmt.Sprintf("%s-error", name),
 	}
 
+	// Rate limits have their own defaults, which win over the 4xx ones.
+	if statusCode == http.StatusTooManyRequests {
+		keys = append([]string{fmt.Sprintf("%s-ratelimit", name)}, keys...)
+	}
+
 	for _, key := range keys {
 		if val, ok := utils.Defaults[key]; ok {
 			return val
@@ -63,7 +92,7 @@ func mapStatus(name string, statusCode int) string {
 	return utils.Defaults[name]
 }
 
-func NewFaceProviderFromEnvironment() *FaceProvider {
+func NewFaceProviderFromEnvironment() (*FaceProvider, error) {
 	fprv := &FaceProvider{
 		BaseProvider: BaseProvider{
 			Name: "Face",
@@ -80,37 +109,212 @@ func NewFaceProviderFromEnvironment() *FaceProvider {
 	fprv.BaseProvider.SetupFromEnvironment()
 
 	fprv.smileyService = utils.StringFromEnv("SMILEY_SERVICE", "smiley")
-	fprv.colorService = utils.StringFromEnv("COLOR_SERVICE", "color")
+	fprv.colorService = colorServiceAddress(utils.StringFromEnv("COLOR_SERVICE", "color"))
+
+	fprv.Infof("Face: smileyService http://%s", fprv.smileyService)
+	fprv.Infof("Face: colorService grpc://%s", fprv.colorService)
+
+	fprv.smileyTimeout = utils.Env.Duration("SMILEY_TIMEOUT", 0)
+	fprv.colorTimeout = utils.Env.Duration("COLOR_TIMEOUT", 0)
+	fprv.requestTimeout = utils.Env.Duration("REQUEST_TIMEOUT", 0)
+
+	for name, timeout := range map[string]time.Duration{
+		"SMILEY_TIMEOUT":  fprv.smileyTimeout,
+		"COLOR_TIMEOUT":   fprv.colorTimeout,
+		"REQUEST_TIMEOUT": fprv.requestTimeout,
+	} {
+		if timeout < 0 {
+			utils.Env.Check(name, fmt.Errorf("can't be negative, not %s", timeout))
+		}
+	}
+
+	fprv.Infof("Face: smileyTimeout %s, colorTimeout %s, requestTimeout %s", fprv.smileyTimeout, fprv.colorTimeout, fprv.requestTimeout)
 
-	_, _, err := net.SplitHostPort(fprv.colorService)
+	fprv.setupColorClientFromEnvironment()
+	fprv.setupRetriesFromEnvironment()
+	fprv.setupBreakersFromEnvironment()
+
+	err := fprv.FinishSetup(fprv)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return fprv, nil
+}
+
+// colorServiceAddress makes sure that the color service address has a port,
+// since gRPC insists on one.
+func colorServiceAddress(colorService string) string {
+	_, _, err := net.SplitHostPort(colorService)
 
 	if err != nil {
 		// Most likely we're missing the port, so try to default it.
-		addr := net.ParseIP(fprv.colorService)
+		addr := net.ParseIP(colorService)
 
 		if addr != nil {
 			// Is this an IPv6 address?
-			if strings.Contains(fprv.colorService, ":") {
-				fprv.colorService = fmt.Sprintf("[%s]:80", fprv.colorService)
+			if strings.Contains(colorService, ":") {
+				colorService = fmt.Sprintf("[%s]:80", colorService)
 			} else {
-				fprv.colorService = fmt.Sprintf("%s:80", fprv.colorService)
+				colorService = fmt.Sprintf("%s:80", colorService)
 			}
 		} else {
 			// Probably a hostname.
-			fprv.colorService = fmt.Sprintf("%s:80", fprv.colorService)
+			colorService = fmt.Sprintf("%s:80", colorService)
 		}
 	}
 
-	fprv.Infof("Face: smileyService http://%s", fprv.smileyService)
-	fprv.Infof("Face: colorService grpc://%s", fprv.colorService)
+	return colorService
+}
+
+// services returns the smiley and color service addresses, which can change
+// at runtime.
+func (fprv *FaceProvider) services() (string, string) {
+	fprv.Lock()
+	defer fprv.Unlock()
+
+	return fprv.smileyService, fprv.colorService
+}
+
+// timeouts returns the smiley, color, and overall request timeouts, which
+// can change at runtime. Zero means no timeout.
+func (fprv *FaceProvider) timeouts() (time.Duration, time.Duration, time.Duration) {
+	fprv.Lock()
+	defer fprv.Unlock()
+
+	return fprv.smileyTimeout, fprv.colorTimeout, fprv.requestTimeout
+}
+
+// withTimeout is like context.WithTimeout, except that a zero timeout
+// means no timeout at all.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// parseFaceDuration parses a timeout, or any other duration, from the face
+// section of a config file.
+func parseFaceDuration(name string, value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+
+	if err != nil {
+		return 0, fmt.Errorf("face: invalid %s '%s'", name, value)
+	}
+
+	if duration < 0 {
+		return 0, fmt.Errorf("face: %s can't be negative, not %s", name, value)
+	}
+
+	return duration, nil
+}
 
-	return fprv
+// clientSettingsLocked works out what the gRPC, retry, and circuit breaker
+// settings would be after applying the face section of a config file,
+// starting from the current ones, and checks them. The caller must hold the
+// provider lock.
+func (fprv *FaceProvider) clientSettingsLocked(cfg *FaceConfig) (grpcSettings, *retryPolicy, *breakerConfig, error) {
+	gs := fprv.grpcSettings
+	policy := *fprv.retryPolicy
+	breakers := *fprv.breakerConfig
+
+	if cfg.GRPCLBPolicy != nil {
+		gs.lbPolicy = *cfg.GRPCLBPolicy
+	}
+
+	durations := []struct {
+		name  string
+		value *string
+		dest  *time.Duration
+	}{
+		{"grpcKeepaliveTime", cfg.GRPCKeepaliveTime, &gs.keepaliveTime},
+		{"grpcKeepaliveTimeout", cfg.GRPCKeepaliveTimeout, &gs.keepaliveTimeout},
+		{"retryBackoff", cfg.RetryBackoff, &policy.backoff},
+		{"retryMaxBackoff", cfg.RetryMaxBackoff, &policy.maxBackoff},
+		{"breakerWindow", cfg.BreakerWindow, &breakers.window},
+		{"breakerCooldown", cfg.BreakerCooldown, &breakers.cooldown},
+	}
+
+	for _, d := range durations {
+		if d.value != nil {
+			duration, err := parseFaceDuration(d.name, *d.value)
+
+			if err != nil {
+				return gs, nil, nil, err
+			}
+
+			*d.dest = duration
+		}
+	}
+
+	ints := []struct {
+		value *int
+		dest  *int
+	}{
+		{cfg.RetryMaxAttempts, &policy.maxAttempts},
+		{cfg.RetryBudgetPercent, &policy.budgetPercent},
+		{cfg.BreakerConsecutiveFailures, &breakers.consecutiveFailures},
+		{cfg.BreakerFailureRate, &breakers.failureRate},
+		{cfg.BreakerMinCalls, &breakers.minCalls},
+	}
+
+	for _, i := range ints {
+		if i.value != nil {
+			*i.dest = *i.value
+		}
+	}
+
+	if cfg.RetryStatusCodes != nil {
+		policy.statusCodes = map[int]bool{}
+
+		for _, code := range *cfg.RetryStatusCodes {
+			policy.statusCodes[code] = true
+		}
+	}
+
+	if cfg.RetryGRPCCodes != nil {
+		policy.grpcCodes = map[codes.Code]bool{}
+
+		for _, codeStr := range *cfg.RetryGRPCCodes {
+			code, ok := parseGRPCCode(codeStr)
+
+			if !ok || code == codes.OK {
+				return gs, nil, nil, fmt.Errorf("face: retryGRPCCodes: invalid gRPC error code '%s'", codeStr)
+			}
+
+			policy.grpcCodes[code] = true
+		}
+	}
+
+	err := gs.validate()
+
+	if err == nil {
+		err = policy.validate()
+	}
+
+	if err == nil {
+		err = breakers.validate()
+	}
+
+	if err != nil {
+		return gs, nil, nil, err
+	}
+
+	return gs, &policy, &breakers, nil
 }
 
-func (fprv *FaceProvider) makeSmileyRequest(prvReq *ProviderRequest) *FaceResponse {
+func (fprv *FaceProvider) makeSmileyRequest(ctx context.Context, prvReq *ProviderRequest) *FaceResponse {
 	start := time.Now()
+	smileyService, _ := fprv.services()
+	smileyTimeout, _, _ := fprv.timeouts()
+
+	ctx, cancel := withTimeout(ctx, smileyTimeout)
+	defer cancel()
 
-	url := fmt.Sprintf("http://%s/%s/?row=%d&col=%d", fprv.smileyService, prvReq.subrequest, prvReq.row, prvReq.col)
+	url := fmt.Sprintf("http://%s/%s/?row=%d&col=%d", smileyService, prvReq.subrequest, prvReq.row, prvReq.col)
 
 	fprv.Debugf("HTTP starting (%s) %s", prvReq.InfoStr(), url)
 
@@ -120,23 +324,42 @@ func (fprv *FaceProvider) makeSmileyRequest(prvReq *ProviderRequest) *FaceRespon
 	var response *http.Response
 	var ok bool
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		failed = true
 		rcode = http.StatusInternalServerError
-		rtext = fmt.Sprintf("couldn't create request to %s: %s", fprv.smileyService, err)
+		rtext = fmt.Sprintf("couldn't create request to %s: %s", smileyService, err)
 	}
 
 	if !failed {
 		req.Header.Set(fprv.userHeaderName, prvReq.user)
 		req.Header.Set("User-Agent", prvReq.userAgent)
 
+		if prvReq.fault != "" {
+			req.Header.Set(fprv.faultHeaderName, prvReq.fault)
+		}
+
+		if deadline, ok := ctx.Deadline(); ok {
+			req.Header.Set(fprv.deadlineHeaderName, formatDeadlineBudget(deadline))
+		}
+
+		// Ask for everything that decodeBody can handle, no matter how we
+		// compress our own responses. Setting this ourselves also stops Go
+		// from quietly decompressing gzip for us.
+		req.Header.Set("Accept-Encoding", strings.Join(decodableEncodings, ", "))
+
 		response, err = http.DefaultClient.Do(req)
 
 		if err != nil {
 			failed = true
-			rcode = http.StatusInternalServerError
-			rtext = fmt.Sprintf("couldn't make request to %s: %s", fprv.smileyService, err)
+
+			if ctx.Err() == context.DeadlineExceeded {
+				rcode = http.StatusGatewayTimeout
+				rtext = fmt.Sprintf("timed out waiting for %s", smileyService)
+			} else {
+				rcode = http.StatusInternalServerError
+				rtext = fmt.Sprintf("couldn't make request to %s: %s", smileyService, err)
+			}
 		}
 	}
 
@@ -144,11 +367,27 @@ func (fprv *FaceProvider) makeSmileyRequest(prvReq *ProviderRequest) *FaceRespon
 		defer response.Body.Close()
 
 		rcode = response.StatusCode
-		body, _ := io.ReadAll(response.Body)
+		body, err := io.ReadAll(response.Body)
+
+		if err == nil {
+			body, err = decodeBody(response.Header.Get("Content-Encoding"), body)
+		}
 
 		fprv.Debugf("HTTP %s status %d", url, rcode)
 
-		if rcode != http.StatusOK {
+		// From here on, a response that claims to be OK but isn't gets
+		// reported as a 502: smiley is up, but it's sending us garbage.
+		if err != nil {
+			failed = true
+
+			if ctx.Err() == context.DeadlineExceeded {
+				rcode = http.StatusGatewayTimeout
+				rtext = fmt.Sprintf("timed out reading response from %s", smileyService)
+			} else {
+				rcode = http.StatusBadGateway
+				rtext = fmt.Sprintf("couldn't read response from %s: %s", smileyService, err)
+			}
+		} else if rcode != http.StatusOK {
 			failed = true
 
 			bstr := ""
@@ -157,7 +396,17 @@ func (fprv *FaceProvider) makeSmileyRequest(prvReq *ProviderRequest) *FaceRespon
 				bstr = fmt.Sprintf(" (%s)", string(body))
 			}
 
-			rtext = fmt.Sprintf("error from %s: %03d%s", fprv.smileyService, rcode, bstr)
+			rtext = fmt.Sprintf("error from %s: %03d%s", smileyService, rcode, bstr)
+		}
+
+		if !failed {
+			contentType := response.Header.Get("Content-Type")
+
+			if !strings.HasPrefix(contentType, "application/json") {
+				failed = true
+				rcode = http.StatusBadGateway
+				rtext = fmt.Sprintf("unexpected content type '%s' from %s", contentType, smileyService)
+			}
 		}
 
 		if !failed {
@@ -167,15 +416,17 @@ func (fprv *FaceProvider) makeSmileyRequest(prvReq *ProviderRequest) *FaceRespon
 
 			if err != nil {
 				failed = true
-				rtext = fmt.Sprintf("couldn't decode response from %s: %s", fprv.smileyService, err)
+				rcode = http.StatusBadGateway
+				rtext = fmt.Sprintf("couldn't decode response from %s: %s", smileyService, err)
 			}
 
 			if !failed {
 				rtext, ok = data["smiley"].(string)
 
-				if !ok {
+				if !ok || rtext == "" {
 					failed = true
-					rtext = fmt.Sprintf("no smiley in response from %s", fprv.smileyService)
+					rcode = http.StatusBadGateway
+					rtext = fmt.Sprintf("no smiley in response from %s", smileyService)
 				}
 			}
 		}
@@ -193,27 +444,29 @@ func (fprv *FaceProvider) makeSmileyRequest(prvReq *ProviderRequest) *FaceRespon
 	}
 }
 
-func (fprv *FaceProvider) makeColorRequest(prvReq *ProviderRequest) *FaceResponse {
-	opts := []grpc.DialOption{
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	}
-
-	conn, err := grpc.NewClient(fprv.colorService, opts...)
+func (fprv *FaceProvider) makeColorRequest(ctx context.Context, prvReq *ProviderRequest) *FaceResponse {
+	client, colorService, err := fprv.colorServiceClient()
 
 	if err != nil {
 		return &FaceResponse{
 			statusCode: http.StatusInternalServerError,
-			data:       fmt.Sprintf("couldn't connect to %s: %s", fprv.colorService, err),
+			data:       fmt.Sprintf("couldn't connect to %s: %s", colorService, err),
 		}
 	}
 
-	defer conn.Close()
-
-	client := color.NewColorServiceClient(conn)
-
 	// Anything linked to this variable will transmit request headers.
 	md := metadata.New(map[string]string{"x-faces-user": prvReq.user})
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
+
+	if prvReq.fault != "" {
+		md.Set(fprv.faultHeaderName, prvReq.fault)
+	}
+
+	_, colorTimeout, _ := fprv.timeouts()
+
+	ctx, cancel := withTimeout(ctx, colorTimeout)
+	defer cancel()
+
+	ctx = metadata.NewOutgoingContext(ctx, md)
 
 	colorReq := &color.ColorRequest{
 		Row:    int32(prvReq.row),
@@ -222,7 +475,7 @@ func (fprv *FaceProvider) makeColorRequest(prvReq *ProviderRequest) *FaceRespons
 
 	var colorResp *color.ColorResponse
 
-	fprv.Debugf("gRPC starting (%s) %s", prvReq.InfoStr(), fprv.colorService)
+	fprv.Debugf("gRPC starting (%s) %s", prvReq.InfoStr(), colorService)
 
 	if prvReq.subrequest == "center" {
 		colorResp, err = client.Center(ctx, colorReq)
@@ -234,8 +487,16 @@ func (fprv *FaceProvider) makeColorRequest(prvReq *ProviderRequest) *FaceRespons
 		fprv.Debugf("gRPC (%s) failed: %s", prvReq.InfoStr(), err)
 
 		return &FaceResponse{
-			statusCode: http.StatusInternalServerError,
-			data:       fmt.Sprintf("couldn't get color from %s: %s", fprv.colorService, err),
+			statusCode: httpStatusForGRPCCode(status.Code(err)),
+			grpcCode:   status.Code(err),
+			data:       fmt.Sprintf("couldn't get color from %s: %s", colorService, err),
+		}
+	} else if colorResp.Color == "" {
+		fprv.Debugf("gRPC (%s) returned no color", prvReq.InfoStr())
+
+		return &FaceResponse{
+			statusCode: http.StatusBadGateway,
+			data:       fmt.Sprintf("no color in response from %s", colorService),
 		}
 	} else {
 		fprv.Debugf("gRPC (%s) succeeded: %s", prvReq.InfoStr(), colorResp.Color)
@@ -255,16 +516,31 @@ func (sprv *FaceProvider) Get(prvReq *ProviderRequest) ProviderResponse {
 	var smiley string
 	var color string
 
+	// Both backend calls share the overall request deadline (and our own
+	// caller's deadline, if it's sooner), on top of their own timeouts.
+	_, _, requestTimeout := sprv.timeouts()
+
+	ctx, cancel := withTimeout(prvReq.Context(), requestTimeout)
+	defer cancel()
+
 	// Make HTTP Get requests to the smiley service and the color service in parallel using goroutines
 	smileyCh := make(chan *FaceResponse)
 	colorCh := make(chan *FaceResponse)
 
 	go func() {
-		smileyCh <- sprv.makeSmileyRequest(prvReq)
+		smileyCh <- sprv.withRetries(ctx, "smiley", func() *FaceResponse {
+			return sprv.withBreaker("smiley", func() *FaceResponse {
+				return sprv.makeSmileyRequest(ctx, prvReq)
+			})
+		})
 	}()
 
 	go func() {
-		colorCh <- sprv.makeColorRequest(prvReq)
+		colorCh <- sprv.withRetries(ctx, "color", func() *FaceResponse {
+			return sprv.withBreaker("color", func() *FaceResponse {
+				return sprv.makeColorRequest(ctx, prvReq)
+			})
+		})
 	}()
 
 	// Wait for the responses from both services
@@ -297,7 +573,138 @@ func (sprv *FaceProvider) Get(prvReq *ProviderRequest) ProviderResponse {
 	resp.Add("smiley", smiley)
 	resp.Add("color", color)
 
+	if breakers := sprv.breakerStates(); breakers != nil {
+		resp.Add("breakers", breakers)
+	}
+
 	sprv.Debugf("(%s) %v", prvReq.InfoStr(), resp.Data)
 
 	return resp
 }
+
+// ValidateConfig checks the face section of a config file.
+func (fprv *FaceProvider) ValidateConfig(cfg *ProviderConfig) error {
+	if cfg.Face == nil {
+		return nil
+	}
+
+	if cfg.Face.SmileyService != nil && *cfg.Face.SmileyService == "" {
+		return fmt.Errorf("face: smileyService cannot be empty")
+	}
+
+	if cfg.Face.ColorService != nil && *cfg.Face.ColorService == "" {
+		return fmt.Errorf("face: colorService cannot be empty")
+	}
+
+	for name, value := range map[string]*string{
+		"smileyTimeout":  cfg.Face.SmileyTimeout,
+		"colorTimeout":   cfg.Face.ColorTimeout,
+		"requestTimeout": cfg.Face.RequestTimeout,
+	} {
+		if value != nil {
+			_, err := parseFaceDuration(name, *value)
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	fprv.Lock()
+	defer fprv.Unlock()
+
+	_, _, _, err := fprv.clientSettingsLocked(cfg.Face)
+
+	return err
+}
+
+// ApplyConfig applies the face section of a config file.
+func (fprv *FaceProvider) ApplyConfig(cfg *ProviderConfig) {
+	if cfg.Face == nil {
+		return
+	}
+
+	fprv.Lock()
+	defer fprv.Unlock()
+
+	if cfg.Face.SmileyService != nil {
+		fprv.smileyService = *cfg.Face.SmileyService
+	}
+
+	if cfg.Face.ColorService != nil {
+		fprv.colorService = colorServiceAddress(*cfg.Face.ColorService)
+	}
+
+	// ValidateConfig has already checked the timeouts.
+	if cfg.Face.SmileyTimeout != nil {
+		fprv.smileyTimeout, _ = parseFaceDuration("smileyTimeout", *cfg.Face.SmileyTimeout)
+	}
+
+	if cfg.Face.ColorTimeout != nil {
+		fprv.colorTimeout, _ = parseFaceDuration("colorTimeout", *cfg.Face.ColorTimeout)
+	}
+
+	if cfg.Face.RequestTimeout != nil {
+		fprv.requestTimeout, _ = parseFaceDuration("requestTimeout", *cfg.Face.RequestTimeout)
+	}
+
+	// A new gRPC setting takes effect when colorServiceClient notices it,
+	// and a new retry policy on the next call. The breakers start over, so
+	// only touch them if their settings really changed.
+	gs, policy, breakers, _ := fprv.clientSettingsLocked(cfg.Face)
+
+	fprv.grpcSettings = gs
+	fprv.retryPolicy = policy
+
+	if *breakers != *fprv.breakerConfig {
+		fprv.setBreakerConfigLocked(breakers)
+	}
+}
+
+// ConfigSnapshot returns the current service addresses, timeouts, and gRPC,
+// retry, and circuit breaker settings, for logging config changes.
+func (fprv *FaceProvider) ConfigSnapshot() map[string]interface{} {
+	smileyService, colorService := fprv.services()
+	smileyTimeout, colorTimeout, requestTimeout := fprv.timeouts()
+
+	fprv.Lock()
+	gs := fprv.grpcSettings
+	policy := fprv.retryPolicy
+	breakers := *fprv.breakerConfig
+	fprv.Unlock()
+
+	str := func(d time.Duration) *string {
+		s := d.String()
+		return &s
+	}
+
+	statusCodes := policy.sortedStatusCodes()
+	grpcCodes := policy.sortedGRPCCodes()
+
+	return map[string]interface{}{
+		"face": FaceConfig{
+			SmileyService:  &smileyService,
+			ColorService:   &colorService,
+			SmileyTimeout:  str(smileyTimeout),
+			ColorTimeout:   str(colorTimeout),
+			RequestTimeout: str(requestTimeout),
+
+			GRPCLBPolicy:         &gs.lbPolicy,
+			GRPCKeepaliveTime:    str(gs.keepaliveTime),
+			GRPCKeepaliveTimeout: str(gs.keepaliveTimeout),
+
+			RetryMaxAttempts:   &policy.maxAttempts,
+			RetryStatusCodes:   &statusCodes,
+			RetryGRPCCodes:     &grpcCodes,
+			RetryBackoff:       str(policy.backoff),
+			RetryMaxBackoff:    str(policy.maxBackoff),
+			RetryBudgetPercent: &policy.budgetPercent,
+
+			BreakerConsecutiveFailures: &breakers.consecutiveFailures,
+			BreakerFailureRate:         &breakers.failureRate,
+			BreakerMinCalls:            &breakers.minCalls,
+			BreakerWindow:              str(breakers.window),
+			BreakerCooldown:            str(breakers.cooldown),
+		},
+	}
+}
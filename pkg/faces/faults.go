@@ -0,0 +1,727 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FaultSettings is a snapshot of all the knobs that control how a
+// BaseProvider misbehaves. It's what the admin API hands back for a GET,
+// and what it expects for a PUT.
+type FaultSettings struct {
+	ErrorFraction        int      `json:"errorFraction" yaml:"errorFraction"`
+	ErrorCodes           string   `json:"errorCodes" yaml:"errorCodes"`
+	GRPCErrorCodes       string   `json:"grpcErrorCodes" yaml:"grpcErrorCodes"`
+	LatchFraction        int      `json:"latchFraction" yaml:"latchFraction"`
+	DelayBuckets         []int    `json:"delayBuckets" yaml:"delayBuckets"`
+	LatencyDistribution  string   `json:"latencyDistribution" yaml:"latencyDistribution"`
+	MaxRate              float64  `json:"maxRate" yaml:"maxRate"`
+	RateLimitMode        string   `json:"rateLimitMode" yaml:"rateLimitMode"`
+	RateLimitBurst       int      `json:"rateLimitBurst" yaml:"rateLimitBurst"`
+	RateLimitKey         string   `json:"rateLimitKey" yaml:"rateLimitKey"`
+	ResetFraction        int      `json:"resetFraction" yaml:"resetFraction"`
+	HangFraction         int      `json:"hangFraction" yaml:"hangFraction"`
+	StallFraction        int      `json:"stallFraction" yaml:"stallFraction"`
+	TrickleFraction      int      `json:"trickleFraction" yaml:"trickleFraction"`
+	TrickleBytesPerSec   int      `json:"trickleBytesPerSec" yaml:"trickleBytesPerSec"`
+	MalformedFraction    int      `json:"malformedFraction" yaml:"malformedFraction"`
+	MalformedModes       []string `json:"malformedModes" yaml:"malformedModes"`
+	ConcurrencyLimitMode string   `json:"concurrencyLimitMode" yaml:"concurrencyLimitMode"`
+	MaxInFlight          int      `json:"maxInFlight" yaml:"maxInFlight"`
+	MaxQueue             int      `json:"maxQueue" yaml:"maxQueue"`
+	QueueTimeoutMs       int      `json:"queueTimeoutMs" yaml:"queueTimeoutMs"`
+	Latched              bool     `json:"latched" yaml:"latched"`
+	LatchRecovery        string   `json:"latchRecovery" yaml:"latchRecovery"`
+	Warmup               string   `json:"warmup" yaml:"warmup"`
+	CPUBurnMs            int      `json:"cpuBurnMs" yaml:"cpuBurnMs"`
+	CPUBurnIterations    int      `json:"cpuBurnIterations" yaml:"cpuBurnIterations"`
+	MemoryPerRequestKB   int      `json:"memoryPerRequestKB" yaml:"memoryPerRequestKB"`
+	MemoryLeakKBPerSec   int      `json:"memoryLeakKBPerSec" yaml:"memoryLeakKBPerSec"`
+	Padding              string   `json:"padding" yaml:"padding"`
+	PaddingCompressible  bool     `json:"paddingCompressible" yaml:"paddingCompressible"`
+	Compression          string   `json:"compression" yaml:"compression"`
+
+	// Center and Edge override the settings above for just that kind of
+	// subrequest.
+	Center *FaultProfile `json:"center,omitempty" yaml:"center,omitempty"`
+	Edge   *FaultProfile `json:"edge,omitempty" yaml:"edge,omitempty"`
+}
+
+// FaultSettingsPatch is a partial update to FaultSettings: any field left
+// nil is left alone. This is what PATCH uses.
+type FaultSettingsPatch struct {
+	ErrorFraction        *int      `json:"errorFraction,omitempty" yaml:"errorFraction,omitempty"`
+	ErrorCodes           *string   `json:"errorCodes,omitempty" yaml:"errorCodes,omitempty"`
+	GRPCErrorCodes       *string   `json:"grpcErrorCodes,omitempty" yaml:"grpcErrorCodes,omitempty"`
+	LatchFraction        *int      `json:"latchFraction,omitempty" yaml:"latchFraction,omitempty"`
+	DelayBuckets         *[]int    `json:"delayBuckets,omitempty" yaml:"delayBuckets,omitempty"`
+	LatencyDistribution  *string   `json:"latencyDistribution,omitempty" yaml:"latencyDistribution,omitempty"`
+	MaxRate              *float64  `json:"maxRate,omitempty" yaml:"maxRate,omitempty"`
+	RateLimitMode        *string   `json:"rateLimitMode,omitempty" yaml:"rateLimitMode,omitempty"`
+	RateLimitBurst       *int      `json:"rateLimitBurst,omitempty" yaml:"rateLimitBurst,omitempty"`
+	RateLimitKey         *string   `json:"rateLimitKey,omitempty" yaml:"rateLimitKey,omitempty"`
+	ResetFraction        *int      `json:"resetFraction,omitempty" yaml:"resetFraction,omitempty"`
+	HangFraction         *int      `json:"hangFraction,omitempty" yaml:"hangFraction,omitempty"`
+	StallFraction        *int      `json:"stallFraction,omitempty" yaml:"stallFraction,omitempty"`
+	TrickleFraction      *int      `json:"trickleFraction,omitempty" yaml:"trickleFraction,omitempty"`
+	TrickleBytesPerSec   *int      `json:"trickleBytesPerSec,omitempty" yaml:"trickleBytesPerSec,omitempty"`
+	MalformedFraction    *int      `json:"malformedFraction,omitempty" yaml:"malformedFraction,omitempty"`
+	MalformedModes       *[]string `json:"malformedModes,omitempty" yaml:"malformedModes,omitempty"`
+	ConcurrencyLimitMode *string   `json:"concurrencyLimitMode,omitempty" yaml:"concurrencyLimitMode,omitempty"`
+	MaxInFlight          *int      `json:"maxInFlight,omitempty" yaml:"maxInFlight,omitempty"`
+	MaxQueue             *int      `json:"maxQueue,omitempty" yaml:"maxQueue,omitempty"`
+	QueueTimeoutMs       *int      `json:"queueTimeoutMs,omitempty" yaml:"queueTimeoutMs,omitempty"`
+	Latched              *bool     `json:"latched,omitempty" yaml:"latched,omitempty"`
+	LatchRecovery        *string   `json:"latchRecovery,omitempty" yaml:"latchRecovery,omitempty"`
+	Warmup               *string   `json:"warmup,omitempty" yaml:"warmup,omitempty"`
+	CPUBurnMs            *int      `json:"cpuBurnMs,omitempty" yaml:"cpuBurnMs,omitempty"`
+	CPUBurnIterations    *int      `json:"cpuBurnIterations,omitempty" yaml:"cpuBurnIterations,omitempty"`
+	MemoryPerRequestKB   *int      `json:"memoryPerRequestKB,omitempty" yaml:"memoryPerRequestKB,omitempty"`
+	MemoryLeakKBPerSec   *int      `json:"memoryLeakKBPerSec,omitempty" yaml:"memoryLeakKBPerSec,omitempty"`
+	Padding              *string   `json:"padding,omitempty" yaml:"padding,omitempty"`
+	PaddingCompressible  *bool     `json:"paddingCompressible,omitempty" yaml:"paddingCompressible,omitempty"`
+	Compression          *string   `json:"compression,omitempty" yaml:"compression,omitempty"`
+
+	// Unlike the other fields, Center and Edge are replaced wholesale if
+	// present, so '"edge": {}' clears all the edge overrides.
+	Center *FaultProfile `json:"center,omitempty" yaml:"center,omitempty"`
+	Edge   *FaultProfile `json:"edge,omitempty" yaml:"edge,omitempty"`
+}
+
+// A FaultProfile overrides some of the fault settings for one kind of
+// subrequest (center or edge), so that e.g. only the edge cells of the grid
+// fail. Any field left nil is inherited from the provider-wide settings,
+// except that a subrequest with a profile always gets its own latch: if an
+// edge request latches, only edge requests will fail.
+type FaultProfile struct {
+	ErrorFraction       *int    `json:"errorFraction,omitempty" yaml:"errorFraction,omitempty"`
+	LatchFraction       *int    `json:"latchFraction,omitempty" yaml:"latchFraction,omitempty"`
+	DelayBuckets        *[]int  `json:"delayBuckets,omitempty" yaml:"delayBuckets,omitempty"`
+	LatencyDistribution *string `json:"latencyDistribution,omitempty" yaml:"latencyDistribution,omitempty"`
+	Latched             *bool   `json:"latched,omitempty" yaml:"latched,omitempty"`
+
+	// latency is the distribution worked out from DelayBuckets and
+	// LatencyDistribution, or nil to inherit the provider's.
+	latency *LatencyDistribution
+
+	// latchClock tracks the profile's own latch for the LatchRecovery
+	// policy.
+	latchClock latchClock
+}
+
+// Validate makes sure that a FaultProfile is sane.
+func (fp *FaultProfile) Validate() error {
+	if fp.ErrorFraction != nil && (*fp.ErrorFraction < 0 || *fp.ErrorFraction > 100) {
+		return fmt.Errorf("errorFraction must be between 0 and 100, not %d", *fp.ErrorFraction)
+	}
+
+	if fp.LatchFraction != nil && (*fp.LatchFraction < 0 || *fp.LatchFraction > 100) {
+		return fmt.Errorf("latchFraction must be between 0 and 100, not %d", *fp.LatchFraction)
+	}
+
+	if fp.DelayBuckets != nil {
+		for _, bucket := range *fp.DelayBuckets {
+			if bucket < 0 {
+				return fmt.Errorf("delayBuckets must not be negative (got %d)", bucket)
+			}
+		}
+	}
+
+	if fp.LatencyDistribution != nil {
+		_, err := ParseLatencyDistribution(*fp.LatencyDistribution)
+
+		if err != nil {
+			return fmt.Errorf("latencyDistribution: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// IsEmpty returns true if the profile doesn't override anything.
+func (fp *FaultProfile) IsEmpty() bool {
+	return fp.ErrorFraction == nil && fp.LatchFraction == nil && fp.DelayBuckets == nil &&
+		fp.LatencyDistribution == nil && fp.Latched == nil
+}
+
+// IsLatched returns true if the profile has its own latch, and it's set.
+func (fp *FaultProfile) IsLatched() bool {
+	return fp.Latched != nil && *fp.Latched
+}
+
+// clone makes a deep copy of a FaultProfile, so that snapshots handed out by
+// the provider can't be used to reach back into its state. A nil or empty
+// profile clones to nil.
+func (fp *FaultProfile) clone() *FaultProfile {
+	if fp == nil || fp.IsEmpty() {
+		return nil
+	}
+
+	c := &FaultProfile{latency: fp.latency, latchClock: fp.latchClock}
+
+	if fp.ErrorFraction != nil {
+		v := *fp.ErrorFraction
+		c.ErrorFraction = &v
+	}
+
+	if fp.LatchFraction != nil {
+		v := *fp.LatchFraction
+		c.LatchFraction = &v
+	}
+
+	if fp.DelayBuckets != nil {
+		v := append([]int{}, (*fp.DelayBuckets)...)
+		c.DelayBuckets = &v
+	}
+
+	if fp.LatencyDistribution != nil {
+		v := *fp.LatencyDistribution
+		c.LatencyDistribution = &v
+	}
+
+	if fp.Latched != nil {
+		v := *fp.Latched
+		c.Latched = &v
+	}
+
+	return c
+}
+
+// setupLatency works out the profile's LatencyDistribution: its own
+// latencyDistribution if set, else its own delayBuckets if set, else nil to
+// inherit the provider's.
+func (fp *FaultProfile) setupLatency() error {
+	fp.latency = nil
+
+	if fp.LatencyDistribution != nil && *fp.LatencyDistribution != "" {
+		ld, err := ParseLatencyDistribution(*fp.LatencyDistribution)
+
+		if err != nil {
+			return err
+		}
+
+		fp.latency = ld
+	} else if fp.DelayBuckets != nil {
+		fp.latency = NewBucketsLatencyDistribution(*fp.DelayBuckets)
+
+		if fp.latency == nil {
+			// An explicitly empty list of buckets means "no delay", not
+			// "inherit".
+			fp.latency = NewBucketsLatencyDistribution([]int{0})
+		}
+	}
+
+	return nil
+}
+
+// Validate makes sure that a FaultSettings is sane. We don't silently clamp
+// things here: if you ask for something silly, whether at startup or at
+// runtime, you get told about it.
+func (fs *FaultSettings) Validate() error {
+	if fs.ErrorFraction < 0 || fs.ErrorFraction > 100 {
+		return fmt.Errorf("errorFraction must be between 0 and 100, not %d", fs.ErrorFraction)
+	}
+
+	_, err := parseErrorCodes(fs.ErrorCodes)
+
+	if err != nil {
+		return fmt.Errorf("errorCodes: %w", err)
+	}
+
+	_, err = parseGRPCErrorCodes(fs.GRPCErrorCodes)
+
+	if err != nil {
+		return fmt.Errorf("grpcErrorCodes: %w", err)
+	}
+
+	if fs.LatchFraction < 0 || fs.LatchFraction > 100 {
+		return fmt.Errorf("latchFraction must be between 0 and 100, not %d", fs.LatchFraction)
+	}
+
+	for _, bucket := range fs.DelayBuckets {
+		if bucket < 0 {
+			return fmt.Errorf("delayBuckets must not be negative (got %d)", bucket)
+		}
+	}
+
+	_, err = ParseLatencyDistribution(fs.LatencyDistribution)
+
+	if err != nil {
+		return fmt.Errorf("latencyDistribution: %w", err)
+	}
+
+	if fs.MaxRate < 0 {
+		return fmt.Errorf("maxRate must not be negative, not %f", fs.MaxRate)
+	}
+
+	connectionFractions := []struct {
+		name     string
+		fraction int
+	}{
+		{"resetFraction", fs.ResetFraction},
+		{"hangFraction", fs.HangFraction},
+		{"stallFraction", fs.StallFraction},
+		{"trickleFraction", fs.TrickleFraction},
+		{"malformedFraction", fs.MalformedFraction},
+	}
+
+	for _, cf := range connectionFractions {
+		if cf.fraction < 0 || cf.fraction > 100 {
+			return fmt.Errorf("%s must be between 0 and 100, not %d", cf.name, cf.fraction)
+		}
+	}
+
+	// Zero means the default, so that leaving it out is fine.
+	if fs.TrickleBytesPerSec < 0 {
+		return fmt.Errorf("trickleBytesPerSec must not be negative, not %d", fs.TrickleBytesPerSec)
+	}
+
+	for _, mode := range fs.MalformedModes {
+		if !validMalformedMode(mode) {
+			return fmt.Errorf("unknown malformed response mode '%s'", mode)
+		}
+	}
+
+	err = validateRateLimit(fs.RateLimitMode, fs.RateLimitBurst, fs.RateLimitKey)
+
+	if err != nil {
+		return err
+	}
+
+	err = validateConcurrency(fs.ConcurrencyLimitMode, fs.MaxInFlight, fs.MaxQueue, fs.QueueTimeoutMs)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = ParseLatchRecovery(fs.LatchRecovery)
+
+	if err != nil {
+		return fmt.Errorf("latchRecovery: %w", err)
+	}
+
+	_, err = ParseWarmup(fs.Warmup)
+
+	if err != nil {
+		return fmt.Errorf("warmup: %w", err)
+	}
+
+	resourceCosts := []struct {
+		name string
+		cost int
+	}{
+		{"cpuBurnMs", fs.CPUBurnMs},
+		{"cpuBurnIterations", fs.CPUBurnIterations},
+		{"memoryPerRequestKB", fs.MemoryPerRequestKB},
+		{"memoryLeakKBPerSec", fs.MemoryLeakKBPerSec},
+	}
+
+	for _, rc := range resourceCosts {
+		if rc.cost < 0 {
+			return fmt.Errorf("%s must not be negative, not %d", rc.name, rc.cost)
+		}
+	}
+
+	_, err = parsePadding(fs.Padding)
+
+	if err != nil {
+		return fmt.Errorf("padding: %w", err)
+	}
+
+	_, err = parseCompression(fs.Compression)
+
+	if err != nil {
+		return fmt.Errorf("compression: %w", err)
+	}
+
+	if fs.Center != nil {
+		err := fs.Center.Validate()
+
+		if err != nil {
+			return fmt.Errorf("center: %w", err)
+		}
+	}
+
+	if fs.Edge != nil {
+		err := fs.Edge.Validate()
+
+		if err != nil {
+			return fmt.Errorf("edge: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Apply returns a copy of fs with the patch applied. fs itself is not
+// modified.
+func (patch *FaultSettingsPatch) Apply(fs FaultSettings) FaultSettings {
+	if patch.ErrorFraction != nil {
+		fs.ErrorFraction = *patch.ErrorFraction
+	}
+
+	if patch.ErrorCodes != nil {
+		fs.ErrorCodes = *patch.ErrorCodes
+	}
+
+	if patch.GRPCErrorCodes != nil {
+		fs.GRPCErrorCodes = *patch.GRPCErrorCodes
+	}
+
+	if patch.LatchFraction != nil {
+		fs.LatchFraction = *patch.LatchFraction
+	}
+
+	if patch.DelayBuckets != nil {
+		fs.DelayBuckets = append([]int{}, (*patch.DelayBuckets)...)
+	}
+
+	if patch.LatencyDistribution != nil {
+		fs.LatencyDistribution = *patch.LatencyDistribution
+	}
+
+	if patch.MaxRate != nil {
+		fs.MaxRate = *patch.MaxRate
+	}
+
+	if patch.RateLimitMode != nil {
+		fs.RateLimitMode = *patch.RateLimitMode
+	}
+
+	if patch.RateLimitBurst != nil {
+		fs.RateLimitBurst = *patch.RateLimitBurst
+	}
+
+	if patch.RateLimitKey != nil {
+		fs.RateLimitKey = *patch.RateLimitKey
+	}
+
+	if patch.ResetFraction != nil {
+		fs.ResetFraction = *patch.ResetFraction
+	}
+
+	if patch.HangFraction != nil {
+		fs.HangFraction = *patch.HangFraction
+	}
+
+	if patch.StallFraction != nil {
+		fs.StallFraction = *patch.StallFraction
+	}
+
+	if patch.TrickleFraction != nil {
+		fs.TrickleFraction = *patch.TrickleFraction
+	}
+
+	if patch.TrickleBytesPerSec != nil {
+		fs.TrickleBytesPerSec = *patch.TrickleBytesPerSec
+	}
+
+	if patch.MalformedFraction != nil {
+		fs.MalformedFraction = *patch.MalformedFraction
+	}
+
+	if patch.MalformedModes != nil {
+		fs.MalformedModes = append([]string{}, (*patch.MalformedModes)...)
+	}
+
+	if patch.ConcurrencyLimitMode != nil {
+		fs.ConcurrencyLimitMode = *patch.ConcurrencyLimitMode
+	}
+
+	if patch.MaxInFlight != nil {
+		fs.MaxInFlight = *patch.MaxInFlight
+	}
+
+	if patch.MaxQueue != nil {
+		fs.MaxQueue = *patch.MaxQueue
+	}
+
+	if patch.QueueTimeoutMs != nil {
+		fs.QueueTimeoutMs = *patch.QueueTimeoutMs
+	}
+
+	if patch.Latched != nil {
+		fs.Latched = *patch.Latched
+	}
+
+	if patch.LatchRecovery != nil {
+		fs.LatchRecovery = *patch.LatchRecovery
+	}
+
+	if patch.Warmup != nil {
+		fs.Warmup = *patch.Warmup
+	}
+
+	if patch.CPUBurnMs != nil {
+		fs.CPUBurnMs = *patch.CPUBurnMs
+	}
+
+	if patch.CPUBurnIterations != nil {
+		fs.CPUBurnIterations = *patch.CPUBurnIterations
+	}
+
+	if patch.MemoryPerRequestKB != nil {
+		fs.MemoryPerRequestKB = *patch.MemoryPerRequestKB
+	}
+
+	if patch.MemoryLeakKBPerSec != nil {
+		fs.MemoryLeakKBPerSec = *patch.MemoryLeakKBPerSec
+	}
+
+	if patch.Padding != nil {
+		fs.Padding = *patch.Padding
+	}
+
+	if patch.PaddingCompressible != nil {
+		fs.PaddingCompressible = *patch.PaddingCompressible
+	}
+
+	if patch.Compression != nil {
+		fs.Compression = *patch.Compression
+	}
+
+	if patch.Center != nil {
+		fs.Center = patch.Center.clone()
+	}
+
+	if patch.Edge != nil {
+		fs.Edge = patch.Edge.clone()
+	}
+
+	return fs
+}
+
+// FaultSettings returns a snapshot of the provider's current fault settings.
+func (bprv *BaseProvider) FaultSettings() FaultSettings {
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	return bprv.faultSettingsLocked()
+}
+
+// SetFaultSettings validates and then applies a complete set of fault
+// settings. Everything is applied under the provider lock, so no request
+// will ever see half of an update.
+func (bprv *BaseProvider) SetFaultSettings(fs FaultSettings) error {
+	err := fs.Validate()
+
+	if err != nil {
+		return err
+	}
+
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	old := bprv.faultSettingsLocked()
+	bprv.applyFaultSettingsLocked(fs)
+	bprv.Infof("fault settings changed: %+v => %+v", old, fs)
+
+	return nil
+}
+
+// UpdateFaultSettings applies a patch to the current fault settings, again
+// atomically under the provider lock. It returns the resulting settings.
+func (bprv *BaseProvider) UpdateFaultSettings(patch FaultSettingsPatch) (FaultSettings, error) {
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	old := bprv.faultSettingsLocked()
+	fs := patch.Apply(old)
+
+	err := fs.Validate()
+
+	if err != nil {
+		return old, err
+	}
+
+	bprv.applyFaultSettingsLocked(fs)
+	bprv.Infof("fault settings changed: %+v => %+v", old, fs)
+
+	return fs, nil
+}
+
+// faultSettingsLocked snapshots the fault settings. The caller must hold
+// the provider lock.
+func (bprv *BaseProvider) faultSettingsLocked() FaultSettings {
+	return FaultSettings{
+		ErrorFraction:        bprv.errorFraction,
+		ErrorCodes:           bprv.errorCodes.String(),
+		GRPCErrorCodes:       bprv.grpcErrorCodes.String(),
+		LatchFraction:        bprv.latchFraction,
+		DelayBuckets:         append([]int{}, bprv.delayBuckets...),
+		LatencyDistribution:  bprv.latencySpec,
+		MaxRate:              bprv.maxRate,
+		RateLimitMode:        bprv.rateLimitMode,
+		RateLimitBurst:       bprv.rateLimitBurst,
+		RateLimitKey:         bprv.rateLimitKey,
+		ResetFraction:        bprv.resetFraction,
+		HangFraction:         bprv.hangFraction,
+		StallFraction:        bprv.stallFraction,
+		TrickleFraction:      bprv.trickleFraction,
+		TrickleBytesPerSec:   bprv.trickleBytesPerSec,
+		MalformedFraction:    bprv.malformedFraction,
+		MalformedModes:       append([]string{}, bprv.malformedModes...),
+		ConcurrencyLimitMode: bprv.concurrencyLimitMode,
+		MaxInFlight:          bprv.maxInFlight,
+		MaxQueue:             bprv.maxQueue,
+		QueueTimeoutMs:       bprv.queueTimeoutMs,
+		Latched:              bprv.latched,
+		LatchRecovery:        bprv.latchRecovery.String(),
+		Warmup:               bprv.warmup.String(),
+		CPUBurnMs:            bprv.cpuBurnMs,
+		CPUBurnIterations:    bprv.cpuBurnIterations,
+		MemoryPerRequestKB:   bprv.memoryPerRequestKB,
+		MemoryLeakKBPerSec:   bprv.memoryLeakKBPerSec,
+		Padding:              bprv.paddingSpec,
+		PaddingCompressible:  bprv.paddingCompressible,
+		Compression:          strings.Join(bprv.compression, ","),
+		Center:               bprv.profiles["center"].clone(),
+		Edge:                 bprv.profiles["edge"].clone(),
+	}
+}
+
+// setupLatencyLocked works out which LatencyDistribution to use: the
+// latency spec if there is one, otherwise the delay buckets. The caller must
+// hold the provider lock.
+func (bprv *BaseProvider) setupLatencyLocked() {
+	bprv.latency = NewBucketsLatencyDistribution(bprv.delayBuckets)
+
+	if bprv.latencySpec != "" {
+		ld, err := ParseLatencyDistribution(bprv.latencySpec)
+
+		if err != nil {
+			// Everything gets validated before it's applied, so this
+			// "can't happen".
+			bprv.Warnf("ignoring bad latency distribution %s: %s", bprv.latencySpec, err)
+			bprv.latencySpec = ""
+		} else {
+			bprv.latency = ld
+		}
+	}
+}
+
+// setProfileLocked installs (a copy of) a FaultProfile for a subrequest, or
+// removes the subrequest's profile if fp is nil or empty. The caller must
+// hold the provider lock.
+func (bprv *BaseProvider) setProfileLocked(subrequest string, fp *FaultProfile) {
+	fp = fp.clone()
+
+	if fp == nil {
+		delete(bprv.profiles, subrequest)
+		return
+	}
+
+	err := fp.setupLatency()
+
+	if err != nil {
+		// As with setupLatencyLocked, this "can't happen".
+		bprv.Warnf("%s: ignoring bad latency distribution %s: %s", subrequest, *fp.LatencyDistribution, err)
+		fp.LatencyDistribution = nil
+		fp.setupLatency()
+	}
+
+	// If the profile's latch is being set, the latch clock needs to start
+	// now -- unless it was already set, in which case it keeps running.
+	if fp.IsLatched() {
+		old := bprv.profiles[subrequest]
+
+		if old != nil && old.IsLatched() {
+			fp.latchClock = old.latchClock
+		} else {
+			fp.latchClock.start(time.Now())
+		}
+	}
+
+	if bprv.profiles == nil {
+		bprv.profiles = map[string]*FaultProfile{}
+	}
+
+	bprv.profiles[subrequest] = fp
+}
+
+// applyFaultSettingsLocked applies already-validated fault settings. The
+// caller must hold the provider lock.
+func (bprv *BaseProvider) applyFaultSettingsLocked(fs FaultSettings) {
+	bprv.errorFraction = fs.ErrorFraction
+
+	// These have already been validated, so they can't fail here.
+	bprv.errorCodes, _ = parseErrorCodes(fs.ErrorCodes)
+	bprv.grpcErrorCodes, _ = parseGRPCErrorCodes(fs.GRPCErrorCodes)
+	bprv.latchFraction = fs.LatchFraction
+	bprv.delayBuckets = append([]int{}, fs.DelayBuckets...)
+	bprv.latencySpec = fs.LatencyDistribution
+	bprv.setupLatencyLocked()
+	bprv.maxRate = fs.MaxRate
+	bprv.rateLimitMode = fs.RateLimitMode
+	bprv.rateLimitBurst = fs.RateLimitBurst
+	bprv.rateLimitKey = fs.RateLimitKey
+	bprv.setupRateLimiterLocked()
+	bprv.resetFraction = fs.ResetFraction
+	bprv.hangFraction = fs.HangFraction
+	bprv.stallFraction = fs.StallFraction
+	bprv.trickleFraction = fs.TrickleFraction
+	bprv.trickleBytesPerSec = fs.TrickleBytesPerSec
+
+	if bprv.trickleBytesPerSec == 0 {
+		bprv.trickleBytesPerSec = defaultTrickleBytesPerSec
+	}
+
+	bprv.malformedFraction = fs.MalformedFraction
+
+	// An empty list of modes means all of them, just as with
+	// MALFORMED_MODES.
+	bprv.malformedModes = append([]string{}, fs.MalformedModes...)
+
+	if len(bprv.malformedModes) == 0 {
+		bprv.malformedModes = append(bprv.malformedModes, allMalformedModes...)
+	}
+
+	bprv.concurrencyLimitMode = fs.ConcurrencyLimitMode
+	bprv.maxInFlight = fs.MaxInFlight
+	bprv.maxQueue = fs.MaxQueue
+	bprv.queueTimeoutMs = fs.QueueTimeoutMs
+	bprv.configureConcurrencyLimiterLocked()
+	bprv.SetLatched(fs.Latched)
+
+	// This was validated already, so it can't fail.
+	bprv.latchRecovery, _ = ParseLatchRecovery(fs.LatchRecovery)
+
+	// A new warm-up starts right away, as if we'd just started; the same
+	// one carries on where it was.
+	if fs.Warmup != bprv.warmup.String() {
+		bprv.warmup, _ = ParseWarmup(fs.Warmup)
+		bprv.warmupStart = time.Now()
+	}
+
+	bprv.cpuBurnMs = fs.CPUBurnMs
+	bprv.cpuBurnIterations = fs.CPUBurnIterations
+	bprv.memoryPerRequestKB = fs.MemoryPerRequestKB
+	bprv.memoryLeakKBPerSec = fs.MemoryLeakKBPerSec
+
+	// These were validated already, so they can't fail.
+	bprv.paddingSpec = fs.Padding
+	bprv.padding, _ = parsePadding(fs.Padding)
+	bprv.paddingCompressible = fs.PaddingCompressible
+	bprv.compression, _ = parseCompression(fs.Compression)
+
+	bprv.setProfileLocked("center", fs.Center)
+	bprv.setProfileLocked("edge", fs.Edge)
+}
@@ -35,6 +35,12 @@ type GUIProvider struct {
 	BaseProvider
 	dataPath    string
 	absDataPath string
+	guiSettings
+}
+
+// guiSettings are the GUI settings that can change at runtime, so they're
+// guarded by the provider lock.
+type guiSettings struct {
 	faceService string
 	bgColor     string
 	hideKey     bool
@@ -90,9 +96,125 @@ func NewGUIProviderFromEnvironment() (*GUIProvider, error) {
 	gprv.Infof("edgeSize %d", gprv.edgeSize)
 	gprv.Infof("startActive %v", gprv.startActive)
 
+	if gprv.numRows < 1 {
+		utils.Env.Check("NUM_ROWS", fmt.Errorf("must be at least 1, not %d", gprv.numRows))
+	}
+
+	if gprv.numCols < 1 {
+		utils.Env.Check("NUM_COLS", fmt.Errorf("must be at least 1, not %d", gprv.numCols))
+	}
+
+	if gprv.edgeSize < 0 {
+		utils.Env.Check("EDGE_SIZE", fmt.Errorf("cannot be negative, not %d", gprv.edgeSize))
+	}
+
+	err = gprv.FinishSetup(gprv)
+
+	if err != nil {
+		return nil, err
+	}
+
 	return gprv, nil
 }
 
+// settings returns a copy of the current GUI settings.
+func (gprv *GUIProvider) settings() guiSettings {
+	gprv.Lock()
+	defer gprv.Unlock()
+
+	return gprv.guiSettings
+}
+
+// ValidateConfig checks the gui section of a config file.
+func (gprv *GUIProvider) ValidateConfig(cfg *ProviderConfig) error {
+	gc := cfg.GUI
+
+	if gc == nil {
+		return nil
+	}
+
+	if gc.FaceService != nil && *gc.FaceService == "" {
+		return fmt.Errorf("gui: faceService cannot be empty")
+	}
+
+	if gc.NumRows != nil && *gc.NumRows < 1 {
+		return fmt.Errorf("gui: numRows must be at least 1, not %d", *gc.NumRows)
+	}
+
+	if gc.NumCols != nil && *gc.NumCols < 1 {
+		return fmt.Errorf("gui: numCols must be at least 1, not %d", *gc.NumCols)
+	}
+
+	if gc.EdgeSize != nil && *gc.EdgeSize < 0 {
+		return fmt.Errorf("gui: edgeSize cannot be negative, not %d", *gc.EdgeSize)
+	}
+
+	return nil
+}
+
+// ApplyConfig applies the gui section of a config file.
+func (gprv *GUIProvider) ApplyConfig(cfg *ProviderConfig) {
+	gc := cfg.GUI
+
+	if gc == nil {
+		return
+	}
+
+	gprv.Lock()
+	defer gprv.Unlock()
+
+	if gc.FaceService != nil {
+		gprv.faceService = *gc.FaceService
+	}
+
+	if gc.BgColor != nil {
+		gprv.bgColor = *gc.BgColor
+	}
+
+	if gc.HideKey != nil {
+		gprv.hideKey = *gc.HideKey
+	}
+
+	if gc.ShowPods != nil {
+		gprv.showPods = *gc.ShowPods
+	}
+
+	if gc.NumRows != nil {
+		gprv.numRows = *gc.NumRows
+	}
+
+	if gc.NumCols != nil {
+		gprv.numCols = *gc.NumCols
+	}
+
+	if gc.EdgeSize != nil {
+		gprv.edgeSize = *gc.EdgeSize
+	}
+
+	if gc.StartActive != nil {
+		gprv.startActive = *gc.StartActive
+	}
+}
+
+// ConfigSnapshot returns the current GUI settings, for logging config
+// changes.
+func (gprv *GUIProvider) ConfigSnapshot() map[string]interface{} {
+	gs := gprv.settings()
+
+	return map[string]interface{}{
+		"gui": GUIConfig{
+			FaceService: &gs.faceService,
+			BgColor:     &gs.bgColor,
+			HideKey:     &gs.hideKey,
+			ShowPods:    &gs.showPods,
+			NumRows:     &gs.numRows,
+			NumCols:     &gs.numCols,
+			EdgeSize:    &gs.edgeSize,
+			StartActive: &gs.startActive,
+		},
+	}
+}
+
 // This should never ever be called.
 func (gprv *GUIProvider) Get(prvReq *ProviderRequest) ProviderResponse {
 	// Error fraction, latching, and rate limiting are all handled by the base
@@ -118,6 +240,7 @@ func (gprv *GUIProvider) HTTPGetHandler(w http.ResponseWriter, r *http.Request)
 
 	podID := gprv.hostIP
 	podName := gprv.hostName
+	gs := gprv.settings()
 
 	key := "unknown"
 	rcode := http.StatusNotFound
@@ -126,13 +249,7 @@ func (gprv *GUIProvider) HTTPGetHandler(w http.ResponseWriter, r *http.Request)
 
 	gprv.Debugf("GET %s (user %s, user-agent %s)", r.URL.Path, user, userAgent)
 
-	// Handle readiness checks first (they're simple).
-	if r.URL.Path == "/ready" {
-		key = "ready"
-		rcode = http.StatusOK
-		rtype = "text/plain"
-		rtext = "Ready and waiting!"
-	} else if (r.Method == "GET") && strings.HasPrefix(r.URL.Path, "/face/") {
+	if (r.Method == "GET") && strings.HasPrefix(r.URL.Path, "/face/") {
 		// /face/ is a special case: we forward it to the face workload. This is
 		// here because it allows running the demo without an ingress controller.
 		// (Obviously, this is _NOT_ a good idea outside of demos!)
@@ -141,7 +258,7 @@ func (gprv *GUIProvider) HTTPGetHandler(w http.ResponseWriter, r *http.Request)
 		reqStart := time.Now()
 
 		facePath := strings.TrimPrefix(r.URL.Path, "/face/")
-		url := fmt.Sprintf("http://%s/%s", gprv.faceService, facePath)
+		url := fmt.Sprintf("http://%s/%s", gs.faceService, facePath)
 
 		rq := r.URL.RawQuery
 
@@ -282,13 +399,13 @@ func (gprv *GUIProvider) HTTPGetHandler(w http.ResponseWriter, r *http.Request)
 
 					if interpolate {
 						gprv.Debugf("%s: interpolating", absFilePath)
-						rtext = strings.ReplaceAll(rtext, "%%{color}", gprv.bgColor)
-						rtext = strings.ReplaceAll(rtext, "%%{hide_key}", fmt.Sprintf("%v", gprv.hideKey))
-						rtext = strings.ReplaceAll(rtext, "%%{show_pods}", fmt.Sprintf("%v", gprv.showPods))
-						rtext = strings.ReplaceAll(rtext, "%%{num_rows}", fmt.Sprintf("%d", gprv.numRows))
-						rtext = strings.ReplaceAll(rtext, "%%{num_cols}", fmt.Sprintf("%d", gprv.numCols))
-						rtext = strings.ReplaceAll(rtext, "%%{edge_size}", fmt.Sprintf("%d", gprv.edgeSize))
-						rtext = strings.ReplaceAll(rtext, "%%{start_active}", fmt.Sprintf("%v", gprv.startActive))
+						rtext = strings.ReplaceAll(rtext, "%%{color}", gs.bgColor)
+						rtext = strings.ReplaceAll(rtext, "%%{hide_key}", fmt.Sprintf("%v", gs.hideKey))
+						rtext = strings.ReplaceAll(rtext, "%%{show_pods}", fmt.Sprintf("%v", gs.showPods))
+						rtext = strings.ReplaceAll(rtext, "%%{num_rows}", fmt.Sprintf("%d", gs.numRows))
+						rtext = strings.ReplaceAll(rtext, "%%{num_cols}", fmt.Sprintf("%d", gs.numCols))
+						rtext = strings.ReplaceAll(rtext, "%%{edge_size}", fmt.Sprintf("%d", gs.edgeSize))
+						rtext = strings.ReplaceAll(rtext, "%%{start_active}", fmt.Sprintf("%v", gs.startActive))
 						rtext = strings.ReplaceAll(rtext, "%%{user}", user)
 						rtext = strings.ReplaceAll(rtext, "%%{user_header}", fmt.Sprintf("%v", gprv.userHeaderName))
 						rtext = strings.ReplaceAll(rtext, "%%{user_agent}", userAgent)
@@ -0,0 +1,343 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// A provider is live as long as it's running at all. It's ready unless
+//
+//   - it's shutting down;
+//   - it's been marked not ready with /admin/ready;
+//   - it's still within NOT_READY_FOR of starting (or of a "for" given to
+//     /admin/ready); or
+//   - a readiness fault hits: READINESS_FAIL_FRACTION is the percentage of
+//     readiness checks that fail at random.
+//
+// None of this affects how it answers normal requests, so you can see how
+// Kubernetes and the mesh react to a replica that's perfectly healthy but
+// keeps saying it isn't (or the other way around, with fault injection).
+
+// ReadinessStatus is what /admin/ready returns.
+type ReadinessStatus struct {
+	Ready         bool   `json:"ready"`
+	Reason        string `json:"reason,omitempty"`
+	MarkedReady   bool   `json:"markedReady"`
+	NotReadyUntil string `json:"notReadyUntil,omitempty"`
+	FailFraction  int    `json:"failFraction"`
+}
+
+// ReadinessPatch is what /admin/ready accepts. Ready false marks the
+// provider not ready -- until it's marked ready again, or for a while if
+// For is set -- and Ready true clears both. FailFraction sets the
+// percentage of readiness checks that fail.
+type ReadinessPatch struct {
+	Ready        *bool   `json:"ready,omitempty"`
+	For          *string `json:"for,omitempty"`
+	FailFraction *int    `json:"failFraction,omitempty"`
+}
+
+// Readiness returns whether the provider is ready, and if not, why not. It
+// rolls the dice for READINESS_FAIL_FRACTION, so it's only for actual
+// readiness checks.
+func (bprv *BaseProvider) Readiness() (bool, string) {
+	ready, reason := bprv.configuredReadiness()
+
+	if !ready {
+		return false, reason
+	}
+
+	bprv.lock.Lock()
+	failFraction := bprv.readinessFailFraction
+	bprv.lock.Unlock()
+
+	if (failFraction > 0) && (rand.Intn(100) < failFraction) {
+		return false, "readiness fault"
+	}
+
+	return true, ""
+}
+
+// configuredReadiness is Readiness without any readiness faults: whether
+// the provider is ready as far as its settings go.
+func (bprv *BaseProvider) configuredReadiness() (bool, string) {
+	if bprv.IsDraining() {
+		return false, "shutting down"
+	}
+
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	if bprv.markedNotReady {
+		return false, "marked not ready"
+	}
+
+	remaining := time.Until(bprv.notReadyUntil)
+
+	if remaining > 0 {
+		return false, fmt.Sprintf("not ready for another %s", remaining.Round(time.Second))
+	}
+
+	return true, ""
+}
+
+// ReadinessStatus returns the full readiness state of the provider. Ready
+// doesn't include readiness faults, which only hit actual readiness checks;
+// FailFraction says how often those fail.
+func (bprv *BaseProvider) ReadinessStatus() ReadinessStatus {
+	ready, reason := bprv.configuredReadiness()
+
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	rs := ReadinessStatus{
+		Ready:        ready,
+		Reason:       reason,
+		MarkedReady:  !bprv.markedNotReady,
+		FailFraction: bprv.readinessFailFraction,
+	}
+
+	if time.Now().Before(bprv.notReadyUntil) {
+		rs.NotReadyUntil = bprv.notReadyUntil.Format(time.RFC3339)
+	}
+
+	return rs
+}
+
+// UpdateReadiness applies a ReadinessPatch, atomically. Nothing changes if
+// any of it is invalid.
+func (bprv *BaseProvider) UpdateReadiness(patch ReadinessPatch) error {
+	var duration time.Duration
+
+	if patch.For != nil {
+		if (patch.Ready == nil) || *patch.Ready {
+			return fmt.Errorf("for only makes sense with ready false")
+		}
+
+		d, err := time.ParseDuration(*patch.For)
+
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid duration '%s' for for", *patch.For)
+		}
+
+		duration = d
+	}
+
+	if patch.FailFraction != nil {
+		if *patch.FailFraction < 0 || *patch.FailFraction > 100 {
+			return fmt.Errorf("failFraction must be between 0 and 100, not %d", *patch.FailFraction)
+		}
+	}
+
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	if patch.Ready != nil {
+		switch {
+		case *patch.Ready:
+			bprv.markedNotReady = false
+			bprv.notReadyUntil = time.Time{}
+			bprv.Infof("readiness: marked ready")
+
+		case duration > 0:
+			bprv.markedNotReady = false
+			bprv.notReadyUntil = time.Now().Add(duration)
+			bprv.Infof("readiness: marked not ready for %s", duration)
+
+		default:
+			bprv.markedNotReady = true
+			bprv.Infof("readiness: marked not ready")
+		}
+	}
+
+	if patch.FailFraction != nil {
+		bprv.readinessFailFraction = *patch.FailFraction
+		bprv.Infof("readiness: fail fraction %d", bprv.readinessFailFraction)
+	}
+
+	return nil
+}
+
+// RegisterHealthHandlers adds /livez and /readyz to a ServeMux, along with
+// /ready, the older name for /readyz. These never go through the provider,
+// so they're never subject to fault injection.
+func (bprv *BaseProvider) RegisterHealthHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/livez", bprv.HandleLivez)
+	mux.HandleFunc("/readyz", bprv.HandleReadyz)
+	mux.HandleFunc("/ready", bprv.HandleReadyz)
+}
+
+// HandleLivez implements /livez.
+func (bprv *BaseProvider) HandleLivez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("live\n"))
+}
+
+// HandleReadyz implements /readyz.
+func (bprv *BaseProvider) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	ready, reason := bprv.Readiness()
+
+	w.Header().Set("Content-Type", "text/plain")
+
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(reason + "\n"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ready\n"))
+}
+
+// HandleAdminReady implements /admin/ready. GET returns the readiness
+// state, and PATCH changes it with a ReadinessPatch.
+func (bprv *BaseProvider) HandleAdminReady(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		// Nothing to do here, we'll just return the state below.
+
+	case http.MethodPatch:
+		var patch ReadinessPatch
+
+		err := decodeAdminJSON(r, &patch)
+
+		if err != nil {
+			adminError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err))
+			return
+		}
+
+		err = bprv.UpdateReadiness(patch)
+
+		if err != nil {
+			adminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+	default:
+		adminError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+		return
+	}
+
+	adminJSON(w, http.StatusOK, bprv.ReadinessStatus())
+}
+
+// healthServer implements the standard gRPC health service on top of the
+// provider's readiness. It knows about the server as a whole (the empty
+// service name) and the services listed in services.
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+
+	provider *BaseProvider
+	services []string
+	stopped  chan struct{}
+}
+
+// newHealthServer creates a healthServer and registers it with grpcServer.
+func newHealthServer(grpcServer *grpc.Server, provider *BaseProvider, services ...string) *healthServer {
+	hs := &healthServer{
+		provider: provider,
+		services: services,
+		stopped:  make(chan struct{}),
+	}
+
+	grpc_health_v1.RegisterHealthServer(grpcServer, hs)
+
+	return hs
+}
+
+// stop ends any Watch calls, which would otherwise hold up a graceful stop
+// forever.
+func (hs *healthServer) stop() {
+	close(hs.stopped)
+}
+
+func (hs *healthServer) status(service string) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	known := (service == "")
+
+	for _, s := range hs.services {
+		if s == service {
+			known = true
+		}
+	}
+
+	if !known {
+		return grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN
+	}
+
+	ready, _ := hs.provider.Readiness()
+
+	if !ready {
+		return grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+
+	return grpc_health_v1.HealthCheckResponse_SERVING
+}
+
+func (hs *healthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	st := hs.status(req.Service)
+
+	if st == grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN {
+		return nil, status.Errorf(codes.NotFound, "unknown service %s", req.Service)
+	}
+
+	return &grpc_health_v1.HealthCheckResponse{Status: st}, nil
+}
+
+// Watch checks readiness once a second, sending the status whenever it
+// changes.
+func (hs *healthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc.ServerStreamingServer[grpc_health_v1.HealthCheckResponse]) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	last := grpc_health_v1.HealthCheckResponse_UNKNOWN
+
+	for {
+		st := hs.status(req.Service)
+
+		if st != last {
+			err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: st})
+
+			if err != nil {
+				return err
+			}
+
+			last = st
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+
+		case <-hs.stopped:
+			return status.Error(codes.Unavailable, "shutting down")
+
+		case <-ticker.C:
+		}
+	}
+}
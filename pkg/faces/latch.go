@@ -0,0 +1,263 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A LatchRecovery is the policy for how a latched provider gets out of the
+// error state. It's built from a spec string, one of:
+//
+//	idle:30s          unlatch after 30 seconds with no requests at all (this
+//	                  is the default, and what Faces has always done)
+//	duration:60s      unlatch 60 seconds after latching, no matter what
+//	requests:100      unlatch after failing 100 requests while latched
+//	exponential:10s   recover gradually: while latched, requests fail with a
+//	                  probability that halves every 10 seconds, and the latch
+//	                  clears once that drops below 1%
+//	manual            never unlatch on its own; use the admin API
+//
+// The policy applies to the provider-wide latch and to the latches of the
+// center and edge profiles alike.
+type LatchRecovery struct {
+	spec     string
+	policy   string
+	after    time.Duration
+	requests int
+}
+
+// latchClock keeps track of how long a latch has been set, and how many
+// requests it's failed, for the LatchRecovery policy.
+type latchClock struct {
+	since    time.Time
+	requests int
+}
+
+func (clock *latchClock) start(now time.Time) {
+	clock.since = now
+	clock.requests = 0
+}
+
+// defaultLatchRecovery is the historical behavior: unlatch after thirty
+// seconds without requests.
+var defaultLatchRecovery = &LatchRecovery{
+	spec:   "idle:30s",
+	policy: "idle",
+	after:  30 * time.Second,
+}
+
+// ParseLatchRecovery parses a LatchRecovery spec. An empty spec gives the
+// default policy.
+func ParseLatchRecovery(spec string) (*LatchRecovery, error) {
+	spec = strings.TrimSpace(spec)
+
+	if spec == "" {
+		return defaultLatchRecovery, nil
+	}
+
+	policy, param, _ := strings.Cut(spec, ":")
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	param = strings.TrimSpace(param)
+
+	lr := &LatchRecovery{spec: spec, policy: policy}
+
+	switch policy {
+	case "idle", "duration", "exponential":
+		if param == "" {
+			if policy != "idle" {
+				return nil, fmt.Errorf("%s: %s needs a duration", spec, policy)
+			}
+
+			param = "30s"
+		}
+
+		d, err := time.ParseDuration(param)
+
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%s: invalid duration '%s'", spec, param)
+		}
+
+		lr.after = d
+
+	case "requests":
+		n, err := strconv.Atoi(param)
+
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("%s: invalid request count '%s'", spec, param)
+		}
+
+		lr.requests = n
+
+	case "manual":
+		if param != "" {
+			return nil, fmt.Errorf("%s: manual doesn't take a parameter", spec)
+		}
+
+	default:
+		return nil, fmt.Errorf("%s: unknown latch recovery policy '%s'", spec, policy)
+	}
+
+	return lr, nil
+}
+
+// String returns the spec that the policy was built from.
+func (lr *LatchRecovery) String() string {
+	if lr == nil {
+		lr = defaultLatchRecovery
+	}
+
+	return lr.spec
+}
+
+// ShouldUnlatch decides whether a latch should be cleared now. A nil
+// LatchRecovery is the default policy.
+func (lr *LatchRecovery) ShouldUnlatch(clock *latchClock, lastRequest time.Time, now time.Time) bool {
+	if lr == nil {
+		lr = defaultLatchRecovery
+	}
+
+	switch lr.policy {
+	case "idle":
+		// A latch that was only just set hasn't been idle for long, even
+		// if the last request was ages ago.
+		if clock.since.After(lastRequest) {
+			lastRequest = clock.since
+		}
+
+		return now.Sub(lastRequest) > lr.after
+
+	case "duration":
+		return now.Sub(clock.since) >= lr.after
+
+	case "requests":
+		return clock.requests >= lr.requests
+
+	case "exponential":
+		return lr.FailProbability(clock, now) < 0.01
+	}
+
+	return false
+}
+
+// FailProbability is the chance that a request that hits a latch should
+// actually fail. That's always 1, except for exponential recovery.
+func (lr *LatchRecovery) FailProbability(clock *latchClock, now time.Time) float64 {
+	if lr == nil || lr.policy != "exponential" {
+		return 1.0
+	}
+
+	halfLives := float64(now.Sub(clock.since)) / float64(lr.after)
+
+	return math.Pow(0.5, halfLives)
+}
+
+// latchFailsLocked is called for a request that's run into a latch. It
+// counts the request against the latch, then decides whether the request
+// should really fail (under exponential recovery, it might not). The caller
+// must hold the provider lock.
+func (bprv *BaseProvider) latchFailsLocked(profile *FaultProfile, now time.Time) bool {
+	clock := &bprv.latchClock
+
+	if profile != nil && profile.IsLatched() {
+		clock = &profile.latchClock
+	}
+
+	clock.requests++
+
+	p := bprv.latchRecovery.FailProbability(clock, now)
+
+	return p >= 1.0 || rand.Float64() < p
+}
+
+// latchedGaugeValue is what the latched gauge reports for a subrequest
+// ("all" for the provider-wide latch). It's called at collection time, and
+// runs CheckUnlatch first, so that a latch that the recovery policy has
+// cleared reads as 0 even if no request has come along since to notice.
+func (bprv *BaseProvider) latchedGaugeValue(subrequest string) float64 {
+	bprv.CheckUnlatch(time.Now())
+
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	latched := bprv.latched
+
+	if subrequest != "all" {
+		fp := bprv.profiles[subrequest]
+		latched = fp != nil && fp.IsLatched()
+	}
+
+	if latched {
+		return 1
+	}
+
+	return 0
+}
+
+// Unlatch clears every latch the provider has: its own, and those of the
+// center and edge profiles.
+func (bprv *BaseProvider) Unlatch() {
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	bprv.unlatchAllLocked("manual")
+}
+
+// unlatchAllLocked clears every latch, logging why. The caller must hold
+// the provider lock.
+func (bprv *BaseProvider) unlatchAllLocked(why string) {
+	if bprv.latched {
+		bprv.SetLatched(false)
+		bprv.Infof("unlatched (%s)", why)
+	}
+
+	for subrequest, fp := range bprv.profiles {
+		if fp.IsLatched() {
+			bprv.unlatchProfileLocked(subrequest, fp, why)
+		}
+	}
+}
+
+// unlatchProfileLocked clears one profile's latch. The caller must hold the
+// provider lock.
+func (bprv *BaseProvider) unlatchProfileLocked(subrequest string, fp *FaultProfile, why string) {
+	unlatched := false
+	fp.Latched = &unlatched
+
+	bprv.Infof("%s unlatched (%s)", subrequest, why)
+}
+
+// HandleAdminUnlatch implements /admin/unlatch: a POST clears every latch,
+// which is the only way out under the manual recovery policy (short of
+// PATCHing /admin/faults).
+func (bprv *BaseProvider) HandleAdminUnlatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		adminError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+		return
+	}
+
+	bprv.Unlatch()
+
+	adminJSON(w, http.StatusOK, bprv.FaultSettings())
+}
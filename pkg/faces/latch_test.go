@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseLatchRecovery(t *testing.T) {
+	tests := []struct {
+		spec     string
+		policy   string
+		after    time.Duration
+		requests int
+	}{
+		{"idle", "idle", 30 * time.Second, 0},
+		{"idle:10s", "idle", 10 * time.Second, 0},
+		{"duration:60s", "duration", 60 * time.Second, 0},
+		{"requests:100", "requests", 0, 100},
+		{"Exponential:10s", "exponential", 10 * time.Second, 0},
+		{"manual", "manual", 0, 0},
+	}
+
+	for _, tt := range tests {
+		lr, err := ParseLatchRecovery(tt.spec)
+
+		if err != nil {
+			t.Errorf("%q: %s", tt.spec, err)
+			continue
+		}
+
+		if lr.policy != tt.policy || lr.after != tt.after || lr.requests != tt.requests {
+			t.Errorf("%q: got %+v", tt.spec, *lr)
+		}
+	}
+
+	invalid := []string{
+		"duration",
+		"duration:0s",
+		"exponential:abc",
+		"requests",
+		"requests:0",
+		"manual:10s",
+		"forever",
+	}
+
+	for _, spec := range invalid {
+		_, err := ParseLatchRecovery(spec)
+
+		if err == nil {
+			t.Errorf("%q should be rejected", spec)
+		}
+	}
+
+	lr, err := ParseLatchRecovery("")
+
+	if lr != defaultLatchRecovery || err != nil {
+		t.Errorf("empty spec: got %v, %v; want the default", lr, err)
+	}
+}
+
+func TestLatchRecoveryShouldUnlatch(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := &latchClock{}
+	clock.start(start)
+
+	tests := []struct {
+		spec        string
+		requests    int
+		lastRequest time.Duration
+		now         time.Duration
+		want        bool
+	}{
+		{"idle:30s", 0, 10 * time.Second, 40 * time.Second, false},
+		{"idle:30s", 0, 10 * time.Second, 41 * time.Second, true},
+		{"idle:30s", 0, -time.Hour, 30 * time.Second, false},
+		{"idle:30s", 0, -time.Hour, 31 * time.Second, true},
+		{"duration:60s", 0, 59 * time.Second, 59 * time.Second, false},
+		{"duration:60s", 0, 60 * time.Second, 60 * time.Second, true},
+		{"requests:3", 2, 0, time.Hour, false},
+		{"requests:3", 3, 0, 0, true},
+		{"exponential:10s", 0, 60 * time.Second, 60 * time.Second, false},
+		{"exponential:10s", 0, 70 * time.Second, 70 * time.Second, true},
+		{"manual", 1000, 0, 24 * time.Hour, false},
+	}
+
+	for _, tt := range tests {
+		lr, err := ParseLatchRecovery(tt.spec)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock.requests = tt.requests
+
+		got := lr.ShouldUnlatch(clock, start.Add(tt.lastRequest), start.Add(tt.now))
+
+		if got != tt.want {
+			t.Errorf("%q, %d requests, at %s: got %v, want %v", tt.spec, tt.requests, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestLatchRecoveryFailProbability(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := &latchClock{}
+	clock.start(start)
+
+	lr, _ := ParseLatchRecovery("exponential:10s")
+
+	if p := lr.FailProbability(clock, start.Add(20*time.Second)); math.Abs(p-0.25) > 1e-9 {
+		t.Errorf("exponential after two half-lives: got %f, want 0.25", p)
+	}
+
+	lr, _ = ParseLatchRecovery("duration:10s")
+
+	if p := lr.FailProbability(clock, start.Add(time.Hour)); p != 1 {
+		t.Errorf("duration: got %f, want 1", p)
+	}
+}
+
+// The latched gauge notices a latch expiring with no traffic at all.
+func TestLatchedGaugeWithoutTraffic(t *testing.T) {
+	bprv := newTestProvider()
+	bprv.latchRecovery, _ = ParseLatchRecovery("duration:10s")
+	bprv.latched = true
+	bprv.latchClock.start(time.Now())
+
+	if got := bprv.latchedGaugeValue("all"); got != 1 {
+		t.Errorf("just latched: got %v, want 1", got)
+	}
+
+	bprv.latchClock.start(time.Now().Add(-time.Minute))
+
+	if got := bprv.latchedGaugeValue("all"); got != 0 {
+		t.Errorf("latched a minute ago: got %v, want 0", got)
+	}
+
+	if bprv.IsLatched() {
+		t.Errorf("still latched after the gauge was collected")
+	}
+}
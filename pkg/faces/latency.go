@@ -0,0 +1,620 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A LatencyDistribution describes how long a provider should delay each
+// request. It's built from a spec string of the form
+//
+//	kind:key=value,key=value,...
+//
+// (whitespace works as a separator too, so "lognormal:p50=20ms p99=800ms"
+// is fine). Durations can be written as Go durations ("20ms", "1.5s") or as
+// bare numbers of milliseconds. The kinds are:
+//
+//	fixed:value=100ms              always the same delay ("fixed:100ms" works too)
+//	uniform:min=10ms,max=200ms     uniformly distributed between min and max
+//	normal:mean=100ms,stddev=20ms  normally distributed (clamped at zero)
+//	normal:p50=100ms,p99=150ms     ...or fitted to two percentiles
+//	lognormal:p50=20ms,p99=800ms   log-normal, fitted to two percentiles
+//	pareto:p50=20ms,p99=800ms      Pareto, fitted to two percentiles
+//	pareto:min=10ms,alpha=1.5      ...or given its scale and shape directly
+//	bimodal:fast=20ms,slow=800ms,slowPct=10,jitter=10
+//	                               mostly fast, sometimes slow, with jitter%
+//	                               of noise around each mode
+//
+// The percentiles that can be used for fitting are p50, p75, p90, p95, p99,
+// and p999. Every kind also accepts cap=..., which caps the delay -- you'll
+// probably want that for Pareto, whose tail is very long indeed.
+//
+// The old DELAY_BUCKETS list is also a LatencyDistribution, which picks
+// uniformly from its buckets.
+type LatencyDistribution struct {
+	spec   string
+	capMs  float64
+	sample func() float64
+}
+
+// percentileZ maps the percentiles we know how to fit to their z-scores in
+// the standard normal distribution.
+var percentileZ = map[string]float64{
+	"p50":  0.0,
+	"p75":  0.6744897501960817,
+	"p90":  1.2815515655446004,
+	"p95":  1.6448536269514722,
+	"p99":  2.3263478740408408,
+	"p999": 3.090232306167813,
+}
+
+// percentileP maps the same percentiles to their fractions.
+var percentileP = map[string]float64{
+	"p50":  0.50,
+	"p75":  0.75,
+	"p90":  0.90,
+	"p95":  0.95,
+	"p99":  0.99,
+	"p999": 0.999,
+}
+
+// NewBucketsLatencyDistribution makes a LatencyDistribution that picks
+// uniformly from a list of delays, which is what DELAY_BUCKETS has always
+// done. It returns nil for an empty list.
+func NewBucketsLatencyDistribution(buckets []int) *LatencyDistribution {
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	buckets = append([]int{}, buckets...)
+
+	return &LatencyDistribution{
+		spec: fmt.Sprintf("buckets:%v", buckets),
+		sample: func() float64 {
+			return float64(buckets[rand.Intn(len(buckets))])
+		},
+	}
+}
+
+// ParseLatencyDistribution parses a spec string (see LatencyDistribution).
+// An empty spec gives a nil distribution and no error.
+func ParseLatencyDistribution(spec string) (*LatencyDistribution, error) {
+	spec = strings.TrimSpace(spec)
+
+	if spec == "" {
+		return nil, nil
+	}
+
+	kind, rest, _ := strings.Cut(spec, ":")
+	kind = strings.ToLower(strings.TrimSpace(kind))
+
+	params := map[string]string{}
+
+	for _, field := range strings.FieldsFunc(rest, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	}) {
+		key, value, found := strings.Cut(field, "=")
+
+		if !found {
+			// A bare value is allowed for "fixed:100ms".
+			key, value = "value", field
+		}
+
+		params[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	ld := &LatencyDistribution{spec: spec}
+
+	var err error
+
+	if capStr, ok := params["cap"]; ok {
+		ld.capMs, err = parseLatencyMs(capStr)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: cap: %w", spec, err)
+		}
+
+		delete(params, "cap")
+	}
+
+	switch kind {
+	case "fixed":
+		err = ld.setupFixed(params)
+	case "uniform":
+		err = ld.setupUniform(params)
+	case "normal":
+		err = ld.setupNormal(params)
+	case "lognormal", "log-normal":
+		err = ld.setupLogNormal(params)
+	case "pareto":
+		err = ld.setupPareto(params)
+	case "bimodal":
+		err = ld.setupBimodal(params)
+	default:
+		err = fmt.Errorf("unknown kind '%s'", kind)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", spec, err)
+	}
+
+	return ld, nil
+}
+
+// String returns the spec that the distribution was built from.
+func (ld *LatencyDistribution) String() string {
+	return ld.spec
+}
+
+// SampleMs draws a single delay, in milliseconds, from the distribution.
+func (ld *LatencyDistribution) SampleMs() int {
+	return ld.Sample()
+}
+
+// Sample draws a single value from the distribution, in whatever unit its
+// spec was written in: milliseconds for a latency, but bytes for padding.
+func (ld *LatencyDistribution) Sample() int {
+	value := ld.sample()
+
+	if ld.capMs > 0 && value > ld.capMs {
+		value = ld.capMs
+	}
+
+	if value < 0 || math.IsNaN(value) {
+		value = 0
+	}
+
+	return int(math.Round(value))
+}
+
+// parseLatencyMs parses a duration, or a bare number of milliseconds, into
+// (floating-point) milliseconds.
+func parseLatencyMs(value string) (float64, error) {
+	ms, err := strconv.ParseFloat(value, 64)
+
+	if err == nil {
+		if ms < 0 {
+			return 0, fmt.Errorf("'%s' is negative", value)
+		}
+
+		return ms, nil
+	}
+
+	d, err := time.ParseDuration(value)
+
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a duration", value)
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("'%s' is negative", value)
+	}
+
+	return float64(d) / float64(time.Millisecond), nil
+}
+
+// requireParams parses the given duration params, failing if any are
+// missing, or if there are any params we don't expect.
+func requireParams(params map[string]string, names ...string) (map[string]float64, error) {
+	values := map[string]float64{}
+
+	for _, name := range names {
+		str, ok := params[name]
+
+		if !ok {
+			return nil, fmt.Errorf("missing %s", name)
+		}
+
+		ms, err := parseLatencyMs(str)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		values[name] = ms
+	}
+
+	if len(params) != len(names) {
+		return nil, fmt.Errorf("expected exactly %s", strings.Join(names, ", "))
+	}
+
+	return values, nil
+}
+
+// percentileParams pulls exactly two percentile params (e.g. p50 and p99)
+// out of params, returning them lowest percentile first.
+func percentileParams(params map[string]string) ([]string, []float64, error) {
+	names := []string{}
+
+	for name := range params {
+		if _, ok := percentileZ[name]; !ok {
+			return nil, nil, fmt.Errorf("unexpected parameter %s", name)
+		}
+
+		names = append(names, name)
+	}
+
+	if len(names) != 2 {
+		return nil, nil, fmt.Errorf("need exactly two percentiles (e.g. p50 and p99)")
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return percentileP[names[i]] < percentileP[names[j]]
+	})
+
+	values, err := requireParams(params, names...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if values[names[1]] <= values[names[0]] {
+		return nil, nil, fmt.Errorf("%s must be greater than %s", names[1], names[0])
+	}
+
+	return names, []float64{values[names[0]], values[names[1]]}, nil
+}
+
+func (ld *LatencyDistribution) setupFixed(params map[string]string) error {
+	values, err := requireParams(params, "value")
+
+	if err != nil {
+		return err
+	}
+
+	value := values["value"]
+
+	ld.sample = func() float64 {
+		return value
+	}
+
+	return nil
+}
+
+func (ld *LatencyDistribution) setupUniform(params map[string]string) error {
+	values, err := requireParams(params, "min", "max")
+
+	if err != nil {
+		return err
+	}
+
+	lo := values["min"]
+	hi := values["max"]
+
+	if hi < lo {
+		return fmt.Errorf("max must not be less than min")
+	}
+
+	ld.sample = func() float64 {
+		return lo + rand.Float64()*(hi-lo)
+	}
+
+	return nil
+}
+
+func (ld *LatencyDistribution) setupNormal(params map[string]string) error {
+	var mean, stddev float64
+
+	if _, ok := params["mean"]; ok {
+		values, err := requireParams(params, "mean", "stddev")
+
+		if err != nil {
+			return err
+		}
+
+		mean = values["mean"]
+		stddev = values["stddev"]
+	} else {
+		names, values, err := percentileParams(params)
+
+		if err != nil {
+			return err
+		}
+
+		za := percentileZ[names[0]]
+		zb := percentileZ[names[1]]
+
+		stddev = (values[1] - values[0]) / (zb - za)
+		mean = values[0] - za*stddev
+	}
+
+	ld.sample = func() float64 {
+		return mean + rand.NormFloat64()*stddev
+	}
+
+	return nil
+}
+
+func (ld *LatencyDistribution) setupLogNormal(params map[string]string) error {
+	names, values, err := percentileParams(params)
+
+	if err != nil {
+		return err
+	}
+
+	if values[0] <= 0 {
+		return fmt.Errorf("%s must be greater than zero", names[0])
+	}
+
+	za := percentileZ[names[0]]
+	zb := percentileZ[names[1]]
+
+	sigma := (math.Log(values[1]) - math.Log(values[0])) / (zb - za)
+	mu := math.Log(values[0]) - za*sigma
+
+	ld.sample = func() float64 {
+		return math.Exp(mu + rand.NormFloat64()*sigma)
+	}
+
+	return nil
+}
+
+func (ld *LatencyDistribution) setupPareto(params map[string]string) error {
+	var xm, alpha float64
+
+	if _, ok := params["alpha"]; ok {
+		alphaStr := params["alpha"]
+		delete(params, "alpha")
+
+		values, err := requireParams(params, "min")
+
+		if err != nil {
+			return err
+		}
+
+		xm = values["min"]
+		alpha, err = strconv.ParseFloat(alphaStr, 64)
+
+		if err != nil || alpha <= 0 {
+			return fmt.Errorf("alpha must be a positive number, not '%s'", alphaStr)
+		}
+	} else {
+		names, values, err := percentileParams(params)
+
+		if err != nil {
+			return err
+		}
+
+		if values[0] <= 0 {
+			return fmt.Errorf("%s must be greater than zero", names[0])
+		}
+
+		// The Pareto quantile function is x_p = xm * (1-p)^(-1/alpha), so
+		// two quantiles pin down both alpha and xm.
+		pa := percentileP[names[0]]
+		pb := percentileP[names[1]]
+
+		invAlpha := (math.Log(values[1]) - math.Log(values[0])) / (math.Log(1-pa) - math.Log(1-pb))
+		alpha = 1 / invAlpha
+		xm = values[0] * math.Pow(1-pa, invAlpha)
+	}
+
+	if xm <= 0 {
+		return fmt.Errorf("min must be greater than zero")
+	}
+
+	ld.sample = func() float64 {
+		// 1 - Float64() is in (0, 1], so we never divide by zero.
+		return xm / math.Pow(1-rand.Float64(), 1/alpha)
+	}
+
+	return nil
+}
+
+func (ld *LatencyDistribution) setupBimodal(params map[string]string) error {
+	slowPct := 10.0
+	jitter := 10.0
+
+	for _, name := range []string{"slowPct", "jitter"} {
+		str, ok := params[name]
+
+		if !ok {
+			continue
+		}
+
+		delete(params, name)
+
+		pct, err := strconv.ParseFloat(str, 64)
+
+		if err != nil || pct < 0 || pct > 100 {
+			return fmt.Errorf("%s must be a percentage, not '%s'", name, str)
+		}
+
+		if name == "slowPct" {
+			slowPct = pct
+		} else {
+			jitter = pct
+		}
+	}
+
+	values, err := requireParams(params, "fast", "slow")
+
+	if err != nil {
+		return err
+	}
+
+	fast := values["fast"]
+	slow := values["slow"]
+
+	ld.sample = func() float64 {
+		mode := fast
+
+		if rand.Float64()*100 < slowPct {
+			mode = slow
+		}
+
+		return mode * (1 + (rand.Float64()*2-1)*jitter/100)
+	}
+
+	return nil
+}
+
+// latencyHistogramBounds are the upper bounds, in milliseconds, of the
+// buckets used by HandleDebugLatency.
+var latencyHistogramBounds = []int{
+	1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000, 30000,
+}
+
+// LatencyHistogram summarizes a bunch of samples from a distribution.
+type LatencyHistogram struct {
+	Distribution string          `json:"distribution"`
+	Samples      int             `json:"samples"`
+	MeanMs       float64         `json:"meanMs"`
+	Percentiles  map[string]int  `json:"percentiles"`
+	Buckets      []LatencyBucket `json:"buckets"`
+}
+
+// LatencyBucket is one bucket of a LatencyHistogram. LeMs is the upper
+// bound of the bucket, or -1 for the overflow bucket.
+type LatencyBucket struct {
+	LeMs  int `json:"leMs"`
+	Count int `json:"count"`
+}
+
+// Histogram draws samples from the distribution and summarizes them.
+func (ld *LatencyDistribution) Histogram(samples int) LatencyHistogram {
+	values := make([]int, samples)
+	total := 0.0
+
+	for i := range values {
+		values[i] = ld.SampleMs()
+		total += float64(values[i])
+	}
+
+	sort.Ints(values)
+
+	hist := LatencyHistogram{
+		Distribution: ld.String(),
+		Samples:      samples,
+		Percentiles:  map[string]int{},
+	}
+
+	if samples == 0 {
+		return hist
+	}
+
+	hist.MeanMs = total / float64(samples)
+
+	for name, p := range percentileP {
+		hist.Percentiles[name] = values[int(math.Ceil(p*float64(samples)))-1]
+	}
+
+	i := 0
+
+	for _, bound := range latencyHistogramBounds {
+		count := 0
+
+		for i < len(values) && values[i] <= bound {
+			count++
+			i++
+		}
+
+		hist.Buckets = append(hist.Buckets, LatencyBucket{LeMs: bound, Count: count})
+	}
+
+	hist.Buckets = append(hist.Buckets, LatencyBucket{LeMs: -1, Count: len(values) - i})
+
+	return hist
+}
+
+// HandleDebugLatency implements /debug/latency, which samples the current
+// latency distribution (or the one given in the "spec" query parameter, so
+// you can try one out before applying it) and returns a histogram. Use
+// "samples" to change the number of samples, and "format=text" to get a
+// little bar chart instead of JSON.
+func (bprv *BaseProvider) HandleDebugLatency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		adminError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+		return
+	}
+
+	query := r.URL.Query()
+
+	samples := 10000
+
+	if samplesStr := query.Get("samples"); samplesStr != "" {
+		n, err := strconv.Atoi(samplesStr)
+
+		if err != nil || n < 1 || n > 1000000 {
+			adminError(w, http.StatusBadRequest, fmt.Sprintf("samples must be between 1 and 1000000, not '%s'", samplesStr))
+			return
+		}
+
+		samples = n
+	}
+
+	var ld *LatencyDistribution
+
+	if spec := query.Get("spec"); spec != "" {
+		var err error
+		ld, err = ParseLatencyDistribution(spec)
+
+		if err != nil {
+			adminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	} else {
+		bprv.lock.Lock()
+		ld = bprv.latency
+		bprv.lock.Unlock()
+	}
+
+	if ld == nil {
+		ld = &LatencyDistribution{
+			spec:   "none",
+			sample: func() float64 { return 0 },
+		}
+	}
+
+	hist := ld.Histogram(samples)
+
+	if query.Get("format") != "text" {
+		adminJSON(w, http.StatusOK, hist)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "%s (%d samples, mean %.1fms)\n", hist.Distribution, hist.Samples, hist.MeanMs)
+
+	for _, name := range []string{"p50", "p75", "p90", "p95", "p99", "p999"} {
+		fmt.Fprintf(w, "  %-4s %6dms\n", name, hist.Percentiles[name])
+	}
+
+	fmt.Fprintln(w)
+
+	for _, bucket := range hist.Buckets {
+		label := fmt.Sprintf("<= %dms", bucket.LeMs)
+
+		if bucket.LeMs < 0 {
+			label = "more"
+		}
+
+		bar := strings.Repeat("#", int(math.Round(60*float64(bucket.Count)/float64(hist.Samples))))
+		fmt.Fprintf(w, "  %10s %7d %s\n", label, bucket.Count, bar)
+	}
+}
@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestParseLatencyDistribution(t *testing.T) {
+	valid := []string{
+		"fixed:100ms",
+		"fixed:value=100",
+		"uniform:min=10ms,max=200ms",
+		"uniform:min=10 max=10",
+		"normal:mean=100ms,stddev=20ms",
+		"normal:p50=100ms,p99=150ms",
+		"lognormal:p50=20ms,p99=800ms",
+		"log-normal:p90=1s p999=5s",
+		"pareto:p50=20ms,p99=800ms,cap=2s",
+		"pareto:min=10ms,alpha=1.5",
+		"bimodal:fast=20ms,slow=800ms,slowPct=10,jitter=10",
+	}
+
+	for _, spec := range valid {
+		ld, err := ParseLatencyDistribution(spec)
+
+		if err != nil {
+			t.Errorf("%q: %s", spec, err)
+			continue
+		}
+
+		if ld.String() != spec {
+			t.Errorf("%q: String() gave %q", spec, ld.String())
+		}
+
+		for i := 0; i < 100; i++ {
+			if ms := ld.SampleMs(); ms < 0 {
+				t.Errorf("%q: negative sample %d", spec, ms)
+				break
+			}
+		}
+	}
+
+	invalid := []string{
+		"fixed",
+		"fixed:abc",
+		"fixed:-5ms",
+		"uniform:min=200ms,max=10ms",
+		"uniform:min=10ms",
+		"uniform:min=10ms,max=20ms,extra=1",
+		"normal:p50=100ms",
+		"normal:p50=100ms,p99=50ms",
+		"normal:p50=100ms,p98=150ms",
+		"lognormal:p50=0,p99=800ms",
+		"pareto:min=10ms,alpha=0",
+		"pareto:min=0,alpha=1.5",
+		"cauchy:p50=10ms,p99=20ms",
+		"fixed:100ms,cap=abc",
+	}
+
+	for _, spec := range invalid {
+		_, err := ParseLatencyDistribution(spec)
+
+		if err == nil {
+			t.Errorf("%q should be rejected", spec)
+		}
+	}
+
+	ld, err := ParseLatencyDistribution("")
+
+	if ld != nil || err != nil {
+		t.Errorf("empty spec: got %v, %v; want nil, nil", ld, err)
+	}
+}
+
+func TestLatencyDistributionSamples(t *testing.T) {
+	ld, _ := ParseLatencyDistribution("fixed:1.5s")
+
+	if ms := ld.SampleMs(); ms != 1500 {
+		t.Errorf("fixed:1.5s sampled %d, want 1500", ms)
+	}
+
+	ld, _ = ParseLatencyDistribution("uniform:min=10ms,max=20ms")
+
+	for i := 0; i < 1000; i++ {
+		if ms := ld.SampleMs(); ms < 10 || ms > 20 {
+			t.Fatalf("uniform sample %d out of range", ms)
+		}
+	}
+
+	// Pareto's tail is long, but the cap holds.
+	ld, _ = ParseLatencyDistribution("pareto:min=10ms,alpha=0.5,cap=50ms")
+
+	for i := 0; i < 1000; i++ {
+		if ms := ld.SampleMs(); ms < 10 || ms > 50 {
+			t.Fatalf("capped pareto sample %d out of range", ms)
+		}
+	}
+}
+
+// The percentile fits should land the median about where it was asked for.
+func TestLatencyDistributionMedian(t *testing.T) {
+	for _, spec := range []string{
+		"normal:p50=100ms,p99=150ms",
+		"lognormal:p50=100ms,p99=800ms",
+		"pareto:p50=100ms,p99=800ms",
+	} {
+		ld, err := ParseLatencyDistribution(spec)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		samples := make([]int, 10001)
+
+		for i := range samples {
+			samples[i] = ld.SampleMs()
+		}
+
+		sort.Ints(samples)
+
+		if median := samples[len(samples)/2]; median < 90 || median > 110 {
+			t.Errorf("%q: median %d, want about 100", spec, median)
+		}
+	}
+}
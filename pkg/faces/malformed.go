@@ -0,0 +1,155 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Malformed responses look successful, but aren't:
+//
+//   - invalid-json mangles the JSON body so that it won't parse
+//   - missing-field leaves the payload (e.g. smiley or color) out of the body
+//   - wrong-content-type sends the body as text/html
+//   - truncated promises the whole body, but only sends half of it
+//
+// gRPC doesn't have bodies or content types, so for color, every kind of
+// malformed response is just a response with an empty color.
+const (
+	MalformedInvalidJSON      = "invalid-json"
+	MalformedMissingField     = "missing-field"
+	MalformedWrongContentType = "wrong-content-type"
+	MalformedTruncated        = "truncated"
+)
+
+var allMalformedModes = []string{
+	MalformedInvalidJSON,
+	MalformedMissingField,
+	MalformedWrongContentType,
+	MalformedTruncated,
+}
+
+// validMalformedMode returns true if mode is a kind of malformed response.
+func validMalformedMode(mode string) bool {
+	for _, m := range allMalformedModes {
+		if m == mode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseMalformedModes parses a comma-separated list of malformed response
+// modes, as used by MALFORMED_MODES. An empty list means all of them.
+func parseMalformedModes(modesStr string) ([]string, error) {
+	modes := []string{}
+
+	for _, mode := range strings.Split(modesStr, ",") {
+		mode = strings.TrimSpace(mode)
+
+		if mode == "" {
+			continue
+		}
+
+		if !validMalformedMode(mode) {
+			return nil, fmt.Errorf("unknown malformed response mode '%s'", mode)
+		}
+
+		modes = append(modes, mode)
+	}
+
+	if len(modes) == 0 {
+		modes = append(modes, allMalformedModes...)
+	}
+
+	return modes, nil
+}
+
+// pickMalformedLocked decides whether to send a malformed response and, if
+// so, which kind. The caller must hold the provider lock.
+func (bprv *BaseProvider) pickMalformedLocked() string {
+	if bprv.malformedFraction <= 0 || len(bprv.malformedModes) == 0 {
+		return ""
+	}
+
+	if rand.Intn(100) >= bprv.malformedFraction {
+		return ""
+	}
+
+	return bprv.malformedModes[rand.Intn(len(bprv.malformedModes))]
+}
+
+// noteMalformed counts a malformed response in the metrics.
+func (bprv *BaseProvider) noteMalformed(mode string) {
+	bprv.Debugf("malformed response: %s", mode)
+
+	if bprv.malformedResponses != nil {
+		bprv.malformedResponses.WithLabelValues(bprv.Name, bprv.hostName, mode).Inc()
+	}
+}
+
+// withoutPayload returns a copy of a response's data with only the errors
+// left in, for missing-field.
+func withoutPayload(data map[string]interface{}) map[string]interface{} {
+	stripped := map[string]interface{}{}
+
+	if errors, exists := data["errors"]; exists {
+		stripped["errors"] = errors
+	}
+
+	return stripped
+}
+
+// writeMalformed sends a malformed HTTP response. missing-field has already
+// been taken care of while building the body, so this handles the other
+// modes.
+func (bsrv *BaseHTTPServer) writeMalformed(w http.ResponseWriter, r *http.Request, statusCode int, contentType string, body []byte, mode string) {
+	bsrv.provider.noteMalformed(mode)
+
+	switch mode {
+	case MalformedInvalidJSON:
+		// Swap the first colon for an equals sign, which is still plausible
+		// at a glance but won't parse.
+		body = bytes.Replace(body, []byte(":"), []byte("="), 1)
+
+	case MalformedWrongContentType:
+		contentType = "text/html"
+
+	case MalformedTruncated:
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		bsrv.standardHeaders(w, r, statusCode, contentType)
+		w.Write(body[:len(body)/2])
+
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+
+		// Aborting the handler makes the server drop the connection
+		// without finishing the body.
+		panic(http.ErrAbortHandler)
+	}
+
+	bsrv.standardHeaders(w, r, statusCode, contentType)
+	w.Write(body)
+}
@@ -0,0 +1,245 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+
+	// Importing this registers gzip as a gRPC compressor.
+	_ "google.golang.org/grpc/encoding/gzip"
+)
+
+// Padding makes responses bigger, so that you can see what payload size
+// does to latency and bandwidth. The padding setting is either a plain byte
+// count, like "4096", or any LatencyDistribution spec with sizes in bytes
+// instead of milliseconds, like "lognormal:p50=1000,p99=100000". The padding
+// goes into a "padding" field of the response. Normally it's random
+// characters, which don't compress at all; with paddingCompressible, it's
+// repetitive text that compresses very well.
+//
+// Compression makes responses smaller again: the compression setting is a
+// list of encodings (gzip and zstd) in order of preference, and a response
+// is compressed with the first one that the client says it accepts. Empty
+// means never to compress.
+
+// Compression encodings.
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+// parsePadding parses a padding spec. An empty spec gives nil, meaning no
+// padding.
+func parsePadding(spec string) (*LatencyDistribution, error) {
+	spec = strings.TrimSpace(spec)
+
+	if spec == "" {
+		return nil, nil
+	}
+
+	size, err := strconv.Atoi(spec)
+
+	if err == nil {
+		if size < 0 {
+			return nil, fmt.Errorf("padding must not be negative, not %d", size)
+		}
+
+		return ParseLatencyDistribution(fmt.Sprintf("fixed:%d", size))
+	}
+
+	return ParseLatencyDistribution(spec)
+}
+
+const paddingRandomChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+const paddingText = "All work and no play makes Faces a dull demo. "
+
+// makePadding makes n bytes of padding.
+func makePadding(n int, compressible bool) string {
+	if n <= 0 {
+		return ""
+	}
+
+	if compressible {
+		return strings.Repeat(paddingText, n/len(paddingText)+1)[:n]
+	}
+
+	b := make([]byte, n)
+
+	for i := range b {
+		b[i] = paddingRandomChars[rand.Intn(len(paddingRandomChars))]
+	}
+
+	return string(b)
+}
+
+// parseCompression parses a list of compression encodings.
+func parseCompression(spec string) ([]string, error) {
+	encodings := []string{}
+
+	for _, enc := range strings.Split(spec, ",") {
+		enc = strings.ToLower(strings.TrimSpace(enc))
+
+		switch enc {
+		case "":
+			continue
+
+		case EncodingGzip, EncodingZstd:
+			encodings = append(encodings, enc)
+
+		default:
+			return nil, fmt.Errorf("unknown compression encoding '%s'", enc)
+		}
+	}
+
+	return encodings, nil
+}
+
+// pickEncoding picks the first of our encodings that the client accepts,
+// given the client's list of acceptable encodings (as from an
+// Accept-Encoding header, though we ignore q-values). It returns "" if
+// there's nothing suitable.
+func pickEncoding(ours []string, theirs []string) string {
+	for _, enc := range ours {
+		for _, accepted := range theirs {
+			accepted, _, _ = strings.Cut(accepted, ";")
+
+			if strings.EqualFold(strings.TrimSpace(accepted), enc) {
+				return enc
+			}
+		}
+	}
+
+	return ""
+}
+
+// compressionEncodings returns the provider's compression encodings.
+func (bprv *BaseProvider) compressionEncodings() []string {
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	return bprv.compression
+}
+
+// zstdEncoder and zstdDecoder are shared, since they're safe for concurrent
+// use with EncodeAll and DecodeAll, and expensive to create.
+var zstdEncoder, _ = zstd.NewWriter(nil)
+var zstdDecoder, _ = zstd.NewReader(nil)
+
+// compressBody compresses a response body.
+func compressBody(enc string, body []byte) ([]byte, error) {
+	switch enc {
+	case EncodingGzip:
+		var buf bytes.Buffer
+
+		zw := gzip.NewWriter(&buf)
+
+		_, err := zw.Write(body)
+
+		if err == nil {
+			err = zw.Close()
+		}
+
+		return buf.Bytes(), err
+
+	case EncodingZstd:
+		return zstdEncoder.EncodeAll(body, nil), nil
+	}
+
+	return nil, fmt.Errorf("unknown compression encoding '%s'", enc)
+}
+
+// decodableEncodings is every encoding that decodeBody understands, for
+// the Accept-Encoding header of requests we make ourselves.
+var decodableEncodings = []string{EncodingGzip, EncodingZstd}
+
+// decodeBody decompresses a response body, given its Content-Encoding.
+func decodeBody(enc string, body []byte) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(enc)) {
+	case "", "identity":
+		return body, nil
+
+	case EncodingGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return io.ReadAll(zr)
+
+	case EncodingZstd:
+		return zstdDecoder.DecodeAll(body, nil)
+	}
+
+	return nil, fmt.Errorf("unknown content encoding '%s'", enc)
+}
+
+// zstdCompressor is a gRPC compressor for zstd. Messages are small, so it
+// just buffers them and uses the shared encoder and decoder.
+type zstdCompressor struct{}
+
+func init() {
+	encoding.RegisterCompressor(zstdCompressor{})
+}
+
+func (zstdCompressor) Name() string {
+	return EncodingZstd
+}
+
+func (zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return &zstdWriteCloser{w: w}, nil
+}
+
+func (zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	compressed, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := zstdDecoder.DecodeAll(compressed, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(data), nil
+}
+
+type zstdWriteCloser struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (zwc *zstdWriteCloser) Write(p []byte) (int, error) {
+	return zwc.buf.Write(p)
+}
+
+func (zwc *zstdWriteCloser) Close() error {
+	_, err := zwc.w.Write(zstdEncoder.EncodeAll(zwc.buf.Bytes(), nil))
+	return err
+}
@@ -18,6 +18,7 @@
 package faces
 
 import (
+	"context"
 	"log"
 	"net/http"
 
@@ -25,15 +26,27 @@ import (
 )
 
 // Start the HTTP server for Prometheus metrics.
-func StartPrometheusServer() {
+func StartPrometheusServer() *http.Server {
 	promServer := &http.Server{
 		Handler: promhttp.Handler(),
 		Addr:    ":9090", // Prometheus scrapes metrics from this port
 	}
 
 	go func() {
-		if err := promServer.ListenAndServe(); err != nil {
+		if err := promServer.ListenAndServe(); err != http.ErrServerClosed {
 			log.Fatalf("Unable to start a http server: %v", err)
 		}
 	}()
+
+	return promServer
+}
+
+// StartMetricsServer starts the HTTP server for Prometheus metrics (with
+// StartPrometheusServer), stopping it when the provider shuts down.
+func (bprv *BaseProvider) StartMetricsServer() {
+	promServer := StartPrometheusServer()
+
+	bprv.OnShutdown(func(ctx context.Context) {
+		promServer.Shutdown(ctx)
+	})
 }
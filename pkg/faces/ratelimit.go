@@ -0,0 +1,195 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BuoyantIO/faces-demo/v2/pkg/utils"
+)
+
+// Rate limiting modes. RateLimitAverage is the original Faces behavior: the
+// provider's request rate, averaged over ten seconds, is compared against
+// maxRate. RateLimitTokenBucket allows maxRate requests per second with
+// bursts of up to rateLimitBurst, like most API gateways.
+const (
+	RateLimitAverage     = "average"
+	RateLimitTokenBucket = "token-bucket"
+)
+
+// Rate limiting keys, for token-bucket mode: one bucket for the whole
+// provider, one per user (from the user header), or one per client address.
+const (
+	RateLimitKeyGlobal = "global"
+	RateLimitKeyUser   = "user"
+	RateLimitKeyClient = "client"
+)
+
+// validateRateLimit checks a rate limiting mode, burst, and key.
+func validateRateLimit(mode string, burst int, key string) error {
+	switch mode {
+	case "", RateLimitAverage, RateLimitTokenBucket:
+	default:
+		return fmt.Errorf("rateLimitMode must be %s or %s, not '%s'", RateLimitAverage, RateLimitTokenBucket, mode)
+	}
+
+	if burst < 0 {
+		return fmt.Errorf("rateLimitBurst must not be negative, not %d", burst)
+	}
+
+	switch key {
+	case "", RateLimitKeyGlobal:
+	case RateLimitKeyUser, RateLimitKeyClient:
+		if mode != RateLimitTokenBucket {
+			return fmt.Errorf("rateLimitKey %s needs rateLimitMode %s", key, RateLimitTokenBucket)
+		}
+	default:
+		return fmt.Errorf("rateLimitKey must be %s, %s, or %s, not '%s'", RateLimitKeyGlobal, RateLimitKeyUser, RateLimitKeyClient, key)
+	}
+
+	return nil
+}
+
+// setupRateLimiterLocked makes sure the provider has the rate limiter that
+// its current settings call for. The caller must hold the provider lock.
+func (bprv *BaseProvider) setupRateLimiterLocked() {
+	if bprv.rateLimitMode == "" {
+		bprv.rateLimitMode = RateLimitAverage
+	}
+
+	if bprv.rateLimitKey == "" {
+		bprv.rateLimitKey = RateLimitKeyGlobal
+	}
+
+	if bprv.maxRate < 0.1 {
+		bprv.tokenBucket = nil
+		return
+	}
+
+	switch bprv.rateLimitMode {
+	case RateLimitAverage:
+		bprv.tokenBucket = nil
+
+		// The RateCounter runs its own ticker goroutine forever, so we only
+		// ever create one, and just ignore it if maxRate drops back down.
+		if bprv.rateCounter == nil {
+			bprv.rateCounter = utils.NewRateCounter(10)
+		}
+
+	case RateLimitTokenBucket:
+		burst := bprv.rateLimitBurst
+
+		if burst == 0 {
+			// Default to one second's worth of requests.
+			burst = int(math.Ceil(bprv.maxRate))
+		}
+
+		// Changing the rate or burst starts everyone over with a full
+		// bucket, which is fine for a demo.
+		if bprv.tokenBucket == nil || bprv.tokenBucket.Rate() != bprv.maxRate || bprv.tokenBucket.Burst() != burst {
+			bprv.tokenBucket = utils.NewTokenBucket(bprv.maxRate, burst)
+		}
+	}
+}
+
+// rateLimitKeyFor returns the token bucket key for a request.
+func (bprv *BaseProvider) rateLimitKeyFor(prvReq *ProviderRequest) string {
+	switch bprv.rateLimitKey {
+	case RateLimitKeyUser:
+		return prvReq.user
+
+	case RateLimitKeyClient:
+		return prvReq.clientAddr
+	}
+
+	return ""
+}
+
+// checkRateLimitLocked decides whether the request should be rate limited,
+// and records the outcome in rstat. The caller must hold the provider lock.
+func (bprv *BaseProvider) checkRateLimitLocked(prvReq *ProviderRequest, now time.Time, rstat *BaseRequestStatus) {
+	if bprv.maxRate < 0.1 {
+		return
+	}
+
+	if bprv.tokenBucket != nil {
+		key := bprv.rateLimitKeyFor(prvReq)
+		result := bprv.tokenBucket.Take(key, now)
+		rstat.rateLimit = &result
+
+		if !result.Allowed {
+			who := ""
+
+			if bprv.rateLimitKey != RateLimitKeyGlobal {
+				who = fmt.Sprintf(" for %s %s", bprv.rateLimitKey, key)
+			}
+
+			rstat.ratelimited = true
+			rstat.message = fmt.Sprintf("Rate limited%s (max %.1f RPS, burst %d)", who, bprv.maxRate, result.Limit)
+		}
+
+		return
+	}
+
+	if bprv.rateCounter != nil {
+		bprv.rateCounter.Mark(now)
+		rate := bprv.rateCounter.CurrentRate()
+
+		if rate >= bprv.maxRate {
+			// Bzzzt! Rate limited. The average only moves once a second,
+			// so that's when it's worth trying again.
+			rstat.ratelimited = true
+			rstat.message = fmt.Sprintf("Rate limited (%.1f RPS > max %.1f RPS)", rate, bprv.maxRate)
+			rstat.rateLimit = &utils.TokenBucketResult{RetryAfter: time.Second}
+		}
+	}
+}
+
+// rateLimitHeaders returns the standard rate limiting headers for a
+// request: RateLimit-Limit, RateLimit-Remaining, and RateLimit-Reset
+// whenever a token bucket is in use, and Retry-After when the request was
+// rate limited. Times are in whole seconds, rounded up.
+func rateLimitHeaders(rstat *BaseRequestStatus) http.Header {
+	result := rstat.rateLimit
+
+	if result == nil {
+		return nil
+	}
+
+	seconds := func(d time.Duration) string {
+		return strconv.Itoa(int(math.Ceil(d.Seconds())))
+	}
+
+	headers := http.Header{}
+
+	if result.Limit > 0 {
+		headers.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		headers.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		headers.Set("RateLimit-Reset", seconds(result.Reset))
+	}
+
+	if rstat.ratelimited {
+		headers.Set("Retry-After", seconds(result.RetryAfter))
+	}
+
+	return headers
+}
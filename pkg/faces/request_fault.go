@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A RequestFault is a fault forced on a single request by the fault header
+// (X-Faces-Fault by default), which looks like
+//
+//	X-Faces-Fault: status=503;delay=200ms;latch;conn=reset;malformed=truncated;target=smiley,color
+//
+// All the parts are optional:
+//
+//   - status is the HTTP status to fail with (default: don't fail, unless
+//     latch is given, in which case it's the usual 599)
+//   - delay is how long to delay the request, as a duration or a bare number
+//     of milliseconds (default: no delay)
+//   - latch latches the provider into the error state, just as if
+//     LATCH_FRACTION had triggered
+//   - conn is a connection fault (reset, hang, stall, or trickle) to inflict
+//     on the response
+//   - malformed is the kind of malformed response (invalid-json,
+//     missing-field, wrong-content-type, or truncated) to send, if the
+//     request would otherwise succeed
+//   - target is a comma-separated list of the providers that should act on
+//     the fault (default: all of them)
+//
+// A forced status (or latch) decides the request's fate: the latch and the
+// random error fraction are skipped, and the delay is exactly the forced
+// delay, so that tests can count on exactly what will happen. A fault that
+// doesn't force a status (say, just a delay) leaves a latched provider
+// failing and the error fraction in play, and its delay goes on top of the
+// usual latency. Either way, the header's conn and malformed replace the
+// random connection and malformed-response faults (so leaving them out
+// means none), and warm-up is skipped. The face workload passes the header
+// along to smiley and color, which is what target is for.
+//
+// Providers only honor the header if ALLOW_FAULT_HEADER is set.
+type RequestFault struct {
+	StatusCode int
+	DelayMs    int
+	Latch      bool
+	Connection string
+	Malformed  string
+	Targets    []string
+
+	// source says where the fault came from, for error messages.
+	source string
+}
+
+// ParseRequestFault parses the value of a fault header.
+func ParseRequestFault(header string) (*RequestFault, error) {
+	rf := &RequestFault{source: "fault header"}
+
+	for _, part := range strings.Split(header, ";") {
+		part = strings.TrimSpace(part)
+
+		if part == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(part, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "status":
+			code, err := strconv.Atoi(value)
+
+			if err != nil || code < 100 || code > 599 {
+				return nil, fmt.Errorf("invalid status '%s'", value)
+			}
+
+			rf.StatusCode = code
+
+		case "delay":
+			ms, err := parseLatencyMs(value)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid delay: %w", err)
+			}
+
+			rf.DelayMs = int(ms)
+
+		case "latch":
+			rf.Latch = true
+
+		case "conn":
+			if !validConnectionFault(value) {
+				return nil, fmt.Errorf("invalid connection fault '%s'", value)
+			}
+
+			rf.Connection = value
+
+		case "malformed":
+			if !validMalformedMode(value) {
+				return nil, fmt.Errorf("invalid malformed response mode '%s'", value)
+			}
+
+			rf.Malformed = value
+
+		case "target":
+			for _, target := range strings.Split(value, ",") {
+				target = strings.ToLower(strings.TrimSpace(target))
+
+				if target != "" {
+					rf.Targets = append(rf.Targets, target)
+				}
+			}
+
+		default:
+			return nil, fmt.Errorf("unknown fault parameter '%s'", key)
+		}
+	}
+
+	return rf, nil
+}
+
+// AppliesTo returns true if the fault should be acted on by the named
+// provider.
+func (rf *RequestFault) AppliesTo(name string) bool {
+	if len(rf.Targets) == 0 {
+		return true
+	}
+
+	name = strings.ToLower(name)
+
+	for _, target := range rf.Targets {
+		if target == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestFault returns the RequestFault that this provider should act on
+// for a request, or nil if there isn't one (or if the provider doesn't allow
+// the fault header at all).
+func (bprv *BaseProvider) requestFault(prvReq *ProviderRequest) *RequestFault {
+	if !bprv.allowFaultHeader || prvReq.fault == "" {
+		return nil
+	}
+
+	rf, err := ParseRequestFault(prvReq.fault)
+
+	if err != nil {
+		bprv.Warnf("ignoring bad %s header '%s': %s", bprv.faultHeaderName, prvReq.fault, err)
+		return nil
+	}
+
+	if !rf.AppliesTo(bprv.Name) {
+		return nil
+	}
+
+	rf.source = bprv.faultHeaderName
+
+	return rf
+}
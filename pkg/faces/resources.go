@@ -0,0 +1,180 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Resource faults make requests cost real resources, so that Kubernetes has
+// something to react to (HPA scaling, CPU throttling, OOM kills):
+//
+//   - cpuBurnMs busy-loops for that many milliseconds on every request.
+//   - cpuBurnIterations does that many iterations of busy work (about a
+//     microsecond each, unthrottled) on every request. Unlike cpuBurnMs,
+//     this is a fixed amount of work, so it takes longer when the pod's CPU
+//     is throttled.
+//   - memoryPerRequestKB allocates that much memory for each request, and
+//     holds it until the request is done.
+//   - memoryLeakKBPerSec leaks that much memory every second, whether or
+//     not there are any requests, until something gives. A DELETE to
+//     /admin/leak frees it all again.
+//
+// Rate-limited and shed requests don't cost anything, since they never get
+// as far as doing any work.
+
+// resourceCost is what a single request costs.
+type resourceCost struct {
+	cpuBurnMs         int
+	cpuBurnIterations int
+	memoryKB          int
+}
+
+// burnSink keeps the compiler from optimizing away the busy work. Every
+// request burning CPU writes to it, so it has to be atomic.
+var burnSink uint64
+
+// burnIteration is one iteration of busy work.
+func burnIteration(x uint64) uint64 {
+	for i := 0; i < 1000; i++ {
+		x = x*6364136223846793005 + 1442695040888963407
+	}
+
+	return x
+}
+
+// touchedBytes allocates n bytes and touches every page, so that the memory
+// really is resident rather than just reserved.
+func touchedBytes(n int) []byte {
+	b := make([]byte, n)
+
+	for i := 0; i < n; i += 4096 {
+		b[i] = 1
+	}
+
+	return b
+}
+
+// resourceCostLocked works out what a request should cost. The caller must
+// hold the provider lock.
+func (bprv *BaseProvider) resourceCostLocked() resourceCost {
+	return resourceCost{
+		cpuBurnMs:         bprv.cpuBurnMs,
+		cpuBurnIterations: bprv.cpuBurnIterations,
+		memoryKB:          bprv.memoryPerRequestKB,
+	}
+}
+
+// consumeResources burns CPU and allocates memory for a request. The caller
+// must hold on to the returned memory until the request is done.
+func (bprv *BaseProvider) consumeResources(cost resourceCost) []byte {
+	var held []byte
+
+	if cost.memoryKB > 0 {
+		held = touchedBytes(cost.memoryKB * 1024)
+	}
+
+	if (cost.cpuBurnMs > 0) || (cost.cpuBurnIterations > 0) {
+		start := time.Now()
+		deadline := start.Add(time.Duration(cost.cpuBurnMs) * time.Millisecond)
+		x := uint64(start.UnixNano())
+
+		for time.Now().Before(deadline) {
+			x = burnIteration(x)
+		}
+
+		for i := 0; i < cost.cpuBurnIterations; i++ {
+			x = burnIteration(x)
+		}
+
+		atomic.StoreUint64(&burnSink, x)
+
+		if bprv.cpuBurnSeconds != nil {
+			bprv.cpuBurnSeconds.WithLabelValues(bprv.Name, bprv.hostName).Add(time.Since(start).Seconds())
+		}
+	}
+
+	return held
+}
+
+// leakMemory runs forever, leaking memoryLeakKBPerSec every second.
+func (bprv *BaseProvider) leakMemory() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		bprv.lock.Lock()
+		kb := bprv.memoryLeakKBPerSec
+		bprv.lock.Unlock()
+
+		if kb <= 0 {
+			continue
+		}
+
+		leak := touchedBytes(kb * 1024)
+
+		bprv.lock.Lock()
+		bprv.leaked = append(bprv.leaked, leak)
+		bprv.leakedBytes += len(leak)
+		bprv.lock.Unlock()
+	}
+}
+
+// LeakedBytes returns how much memory has been leaked so far.
+func (bprv *BaseProvider) LeakedBytes() int {
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	return bprv.leakedBytes
+}
+
+// FreeLeakedMemory lets go of all the leaked memory. (The leak carries on,
+// though, unless memoryLeakKBPerSec is zero.)
+func (bprv *BaseProvider) FreeLeakedMemory() {
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	bprv.Infof("freeing %d leaked bytes", bprv.leakedBytes)
+
+	bprv.leaked = nil
+	bprv.leakedBytes = 0
+}
+
+// HandleAdminLeak implements /admin/leak. GET returns how much memory has
+// been leaked, and DELETE frees it.
+func (bprv *BaseProvider) HandleAdminLeak(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		// Nothing to do here, we'll just return the state below.
+
+	case http.MethodDelete:
+		bprv.FreeLeakedMemory()
+
+	default:
+		adminError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+		return
+	}
+
+	adminJSON(w, http.StatusOK, map[string]interface{}{
+		"leakedBytes":        bprv.LeakedBytes(),
+		"memoryLeakKBPerSec": bprv.FaultSettings().MemoryLeakKBPerSec,
+	})
+}
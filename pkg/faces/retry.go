@@ -0,0 +1,313 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/BuoyantIO/faces-demo/v2/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+)
+
+// Face can retry its calls to smiley and color itself, so that you can
+// compare app-level retries with mesh retries:
+//
+//   - RETRY_MAX_ATTEMPTS is the most attempts for a single call, counting
+//     the first one. The default of 1 means no retries at all.
+//   - RETRY_STATUS_CODES (default 502,503,504) lists the HTTP statuses
+//     worth retrying, and RETRY_GRPC_CODES (default Unavailable) the gRPC
+//     codes. A color call that fails outright is judged by its gRPC code;
+//     anything else by its HTTP status.
+//   - Between attempts, face backs off exponentially with full jitter: a
+//     random wait up to RETRY_BACKOFF (default 25ms), doubling every
+//     attempt, up to RETRY_MAX_BACKOFF (default 1s).
+//   - RETRY_BUDGET_PERCENT (default 20) caps retries at that percentage of
+//     calls to each backend, over the last ten seconds. 0 turns the budget
+//     off, which is how you see retries pile more and more load onto a
+//     backend that's already failing.
+//
+// Each attempt gets its own SMILEY_TIMEOUT or COLOR_TIMEOUT, but they all
+// share the overall REQUEST_TIMEOUT, and face never retries once that's
+// passed.
+//
+// These are also the retryMaxAttempts, retryStatusCodes, retryGRPCCodes,
+// retryBackoff, retryMaxBackoff, and retryBudgetPercent settings in the face
+// section of a config file, which take effect for the next call.
+
+// retryBudgetWindow is how many seconds the retry budget looks back over.
+const retryBudgetWindow = 10
+
+// retryPolicy is how face retries its calls. A retryPolicy never changes
+// once it's in use; changing the policy swaps in a new one.
+type retryPolicy struct {
+	maxAttempts   int
+	statusCodes   map[int]bool
+	grpcCodes     map[codes.Code]bool
+	backoff       time.Duration
+	maxBackoff    time.Duration
+	budgetPercent int
+}
+
+// retryable returns true if a response is worth retrying.
+func (policy *retryPolicy) retryable(resp *FaceResponse) bool {
+	if resp.breakerOpen {
+		return false
+	}
+
+	if resp.grpcCode != codes.OK {
+		return policy.grpcCodes[resp.grpcCode]
+	}
+
+	return policy.statusCodes[resp.statusCode]
+}
+
+// backoffFor returns how long to wait after a given (failed) attempt,
+// counting from 1.
+func (policy *retryPolicy) backoffFor(attempt int) time.Duration {
+	ceiling := policy.backoff
+
+	for i := 1; i < attempt && ceiling < policy.maxBackoff; i++ {
+		ceiling *= 2
+	}
+
+	if ceiling > policy.maxBackoff {
+		ceiling = policy.maxBackoff
+	}
+
+	if ceiling <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// validate checks a retry policy from the face section of a config file.
+func (policy *retryPolicy) validate() error {
+	if policy.maxAttempts < 1 {
+		return fmt.Errorf("face: retryMaxAttempts must be at least 1, not %d", policy.maxAttempts)
+	}
+
+	for code := range policy.statusCodes {
+		if code < 400 || code > 599 {
+			return fmt.Errorf("face: retryStatusCodes: invalid HTTP error code %d", code)
+		}
+	}
+
+	if policy.backoff < 0 {
+		return fmt.Errorf("face: retryBackoff can't be negative, not %s", policy.backoff)
+	}
+
+	if policy.maxBackoff < policy.backoff {
+		return fmt.Errorf("face: retryMaxBackoff can't be less than retryBackoff, not %s", policy.maxBackoff)
+	}
+
+	if policy.budgetPercent < 0 {
+		return fmt.Errorf("face: retryBudgetPercent can't be negative, not %d", policy.budgetPercent)
+	}
+
+	return nil
+}
+
+// sortedStatusCodes returns the HTTP statuses worth retrying, in order.
+func (policy *retryPolicy) sortedStatusCodes() []int {
+	codes := make([]int, 0, len(policy.statusCodes))
+
+	for code := range policy.statusCodes {
+		codes = append(codes, code)
+	}
+
+	sort.Ints(codes)
+
+	return codes
+}
+
+// sortedGRPCCodes returns the names of the gRPC codes worth retrying, in
+// order.
+func (policy *retryPolicy) sortedGRPCCodes() []string {
+	names := make([]string, 0, len(policy.grpcCodes))
+
+	for code := range policy.grpcCodes {
+		names = append(names, code.String())
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// retryBudget keeps track of calls and retries to a single backend.
+type retryBudget struct {
+	calls   *utils.RateCounter
+	retries *utils.RateCounter
+}
+
+func newRetryBudget() *retryBudget {
+	return &retryBudget{
+		calls:   utils.NewRateCounter(retryBudgetWindow),
+		retries: utils.NewRateCounter(retryBudgetWindow),
+	}
+}
+
+// allow returns true if there's room in the budget for another retry.
+func (budget *retryBudget) allow(percent int) bool {
+	if percent <= 0 {
+		return true
+	}
+
+	return budget.retries.CurrentRate() < budget.calls.CurrentRate()*float64(percent)/100.0
+}
+
+// setupRetriesFromEnvironment reads the retry policy and sets up the retry
+// metrics.
+func (fprv *FaceProvider) setupRetriesFromEnvironment() {
+	policy := &retryPolicy{
+		maxAttempts:   utils.Env.Int("RETRY_MAX_ATTEMPTS", 1),
+		statusCodes:   map[int]bool{},
+		grpcCodes:     map[codes.Code]bool{},
+		backoff:       utils.Env.Duration("RETRY_BACKOFF", 25*time.Millisecond),
+		maxBackoff:    utils.Env.Duration("RETRY_MAX_BACKOFF", time.Second),
+		budgetPercent: utils.Env.Int("RETRY_BUDGET_PERCENT", 20),
+	}
+
+	if policy.maxAttempts < 1 {
+		utils.Env.Check("RETRY_MAX_ATTEMPTS", fmt.Errorf("must be at least 1, not %d", policy.maxAttempts))
+	}
+
+	for _, code := range utils.Env.IntList("RETRY_STATUS_CODES", []int{502, 503, 504}) {
+		if code < 400 || code > 599 {
+			utils.Env.Check("RETRY_STATUS_CODES", fmt.Errorf("invalid HTTP error code %d", code))
+		}
+
+		policy.statusCodes[code] = true
+	}
+
+	for _, codeStr := range utils.Env.StringList("RETRY_GRPC_CODES", []string{"Unavailable"}) {
+		code, ok := parseGRPCCode(codeStr)
+
+		if !ok || code == codes.OK {
+			utils.Env.Check("RETRY_GRPC_CODES", fmt.Errorf("invalid gRPC error code '%s'", codeStr))
+		}
+
+		policy.grpcCodes[code] = true
+	}
+
+	if policy.backoff < 0 {
+		utils.Env.Check("RETRY_BACKOFF", fmt.Errorf("can't be negative, not %s", policy.backoff))
+	}
+
+	if policy.maxBackoff < policy.backoff {
+		utils.Env.Check("RETRY_MAX_BACKOFF", fmt.Errorf("can't be less than RETRY_BACKOFF, not %s", policy.maxBackoff))
+	}
+
+	if policy.budgetPercent < 0 {
+		utils.Env.Check("RETRY_BUDGET_PERCENT", fmt.Errorf("can't be negative, not %d", policy.budgetPercent))
+	}
+
+	fprv.retryPolicy = policy
+
+	fprv.retryBudgets = map[string]*retryBudget{
+		"smiley": newRetryBudget(),
+		"color":  newRetryBudget(),
+	}
+
+	fprv.backendAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "backend_attempts_total",
+			Help: "Total number of attempts to call each backend, first tries and retries",
+		},
+		[]string{"provider", "hostname", "backend", "attempt"},
+	)
+
+	fprv.retryBudgetExhausted = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "retry_budget_exhausted_total",
+			Help: "Total number of retries skipped because the retry budget was used up",
+		},
+		[]string{"provider", "hostname", "backend"},
+	)
+
+	prometheus.MustRegister(fprv.backendAttempts)
+	prometheus.MustRegister(fprv.retryBudgetExhausted)
+
+	fprv.Infof("Face: retryMaxAttempts %d, retryBackoff %s, retryMaxBackoff %s, retryBudgetPercent %d", policy.maxAttempts, policy.backoff, policy.maxBackoff, policy.budgetPercent)
+}
+
+// retries returns the current retry policy, which can change at runtime.
+func (fprv *FaceProvider) retries() *retryPolicy {
+	fprv.Lock()
+	defer fprv.Unlock()
+
+	return fprv.retryPolicy
+}
+
+// withRetries calls attempt, retrying according to the retry policy, and
+// returns the last response.
+func (fprv *FaceProvider) withRetries(ctx context.Context, backend string, attempt func() *FaceResponse) *FaceResponse {
+	policy := fprv.retries()
+	budget := fprv.retryBudgets[backend]
+
+	budget.calls.Mark(time.Now())
+
+	for n := 1; ; n++ {
+		kind := "first"
+
+		if n > 1 {
+			kind = "retry"
+		}
+
+		fprv.backendAttempts.WithLabelValues(fprv.Name, fprv.hostName, backend, kind).Inc()
+
+		resp := attempt()
+
+		if (n > 1) && (resp.statusCode != http.StatusOK) {
+			resp.data = fmt.Sprintf("%s (after %d attempts)", resp.data, n)
+		}
+
+		if (n >= policy.maxAttempts) || !policy.retryable(resp) || (ctx.Err() != nil) {
+			return resp
+		}
+
+		if !budget.allow(policy.budgetPercent) {
+			fprv.Debugf("%s: retry budget exhausted after attempt %d", backend, n)
+			fprv.retryBudgetExhausted.WithLabelValues(fprv.Name, fprv.hostName, backend).Inc()
+			return resp
+		}
+
+		budget.retries.Mark(time.Now())
+
+		backoff := policy.backoffFor(n)
+
+		fprv.Debugf("%s: attempt %d failed with %d, retrying in %s", backend, n, resp.statusCode, backoff)
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-timer.C:
+
+		case <-ctx.Done():
+			timer.Stop()
+			return resp
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Backoff is full jitter: anywhere from zero up to a ceiling that doubles
+// every attempt, up to the max.
+func TestRetryPolicyBackoffFor(t *testing.T) {
+	policy := &retryPolicy{backoff: 25 * time.Millisecond, maxBackoff: 150 * time.Millisecond}
+
+	ceilings := map[int]time.Duration{
+		1: 25 * time.Millisecond,
+		2: 50 * time.Millisecond,
+		3: 100 * time.Millisecond,
+		4: 150 * time.Millisecond,
+		9: 150 * time.Millisecond,
+	}
+
+	for attempt, ceiling := range ceilings {
+		var longest time.Duration
+
+		for i := 0; i < 1000; i++ {
+			backoff := policy.backoffFor(attempt)
+
+			if backoff < 0 || backoff > ceiling {
+				t.Fatalf("attempt %d: backoff %s out of range (ceiling %s)", attempt, backoff, ceiling)
+			}
+
+			if backoff > longest {
+				longest = backoff
+			}
+		}
+
+		// With a thousand tries, we should get most of the way up.
+		if longest < ceiling/2 {
+			t.Errorf("attempt %d: longest backoff %s, ceiling %s", attempt, longest, ceiling)
+		}
+	}
+
+	policy = &retryPolicy{}
+
+	if backoff := policy.backoffFor(3); backoff != 0 {
+		t.Errorf("no backoff: got %s, want 0", backoff)
+	}
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	policy := &retryPolicy{
+		statusCodes: map[int]bool{503: true},
+		grpcCodes:   map[codes.Code]bool{codes.Unavailable: true},
+	}
+
+	tests := []struct {
+		resp FaceResponse
+		want bool
+	}{
+		{FaceResponse{statusCode: 503}, true},
+		{FaceResponse{statusCode: 500}, false},
+		{FaceResponse{statusCode: 503, grpcCode: codes.Unavailable}, true},
+		{FaceResponse{statusCode: 503, grpcCode: codes.Internal}, false},
+		{FaceResponse{statusCode: 503, breakerOpen: true}, false},
+	}
+
+	for _, tt := range tests {
+		if got := policy.retryable(&tt.resp); got != tt.want {
+			t.Errorf("%+v: got %v, want %v", tt.resp, got, tt.want)
+		}
+	}
+}
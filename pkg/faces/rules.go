@@ -0,0 +1,297 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A FaultRuleMatch says which requests a FaultRule applies to. Every field
+// that's set must match; fields that aren't set match anything.
+type FaultRuleMatch struct {
+	User              string `json:"user,omitempty" yaml:"user,omitempty"`
+	UserAgent         string `json:"userAgent,omitempty" yaml:"userAgent,omitempty"`
+	UserAgentContains string `json:"userAgentContains,omitempty" yaml:"userAgentContains,omitempty"`
+	Subrequest        string `json:"subrequest,omitempty" yaml:"subrequest,omitempty"`
+	Row               *int   `json:"row,omitempty" yaml:"row,omitempty"`
+	Col               *int   `json:"col,omitempty" yaml:"col,omitempty"`
+}
+
+// A FaultRuleAction is what happens when a FaultRule fires. It's the same
+// set of things that the fault header can do.
+type FaultRuleAction struct {
+	Status     int      `json:"status,omitempty" yaml:"status,omitempty"`
+	Delay      string   `json:"delay,omitempty" yaml:"delay,omitempty"`
+	Latch      bool     `json:"latch,omitempty" yaml:"latch,omitempty"`
+	Connection string   `json:"connection,omitempty" yaml:"connection,omitempty"`
+	Malformed  string   `json:"malformed,omitempty" yaml:"malformed,omitempty"`
+	Percent    *float64 `json:"percent,omitempty" yaml:"percent,omitempty"`
+}
+
+// A FaultRule targets a fault at particular requests, e.g. "alice's
+// requests for row 0 fail half the time":
+//
+//	name: alice-top-row
+//	match: { user: alice, row: 0 }
+//	action: { status: 500, percent: 50 }
+type FaultRule struct {
+	Name   string          `json:"name,omitempty" yaml:"name,omitempty"`
+	Match  FaultRuleMatch  `json:"match" yaml:"match"`
+	Action FaultRuleAction `json:"action" yaml:"action"`
+
+	fault *RequestFault
+}
+
+// A FaultRuleSet is an ordered list of FaultRules. For each request, the
+// first rule that matches is the only one considered: if it fires (per its
+// percent), the request gets the rule's fault; if not, the request carries
+// on with the provider's usual random faults.
+type FaultRuleSet struct {
+	Rules []*FaultRule `json:"rules" yaml:"rules"`
+}
+
+// LoadFaultRulesFile reads and validates a FaultRuleSet from a YAML or JSON
+// file.
+func LoadFaultRulesFile(path string) (*FaultRuleSet, error) {
+	raw, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseFaultRules(raw)
+}
+
+// ParseFaultRules parses and validates a FaultRuleSet from YAML or JSON.
+func ParseFaultRules(raw []byte) (*FaultRuleSet, error) {
+	rs := &FaultRuleSet{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+
+	err := decoder.Decode(rs)
+
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("couldn't parse rules: %w", err)
+	}
+
+	err = rs.setup()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+// setup names any unnamed rules, then validates them all and builds their
+// RequestFaults.
+func (rs *FaultRuleSet) setup() error {
+	if rs.Rules == nil {
+		rs.Rules = []*FaultRule{}
+	}
+
+	for i, rule := range rs.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i)
+		}
+
+		err := rule.setup()
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", rule.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// setup validates the rule and builds its RequestFault.
+func (rule *FaultRule) setup() error {
+	action := rule.Action
+
+	if action.Status != 0 && (action.Status < 100 || action.Status > 599) {
+		return fmt.Errorf("invalid status %d", action.Status)
+	}
+
+	if action.Connection != "" && !validConnectionFault(action.Connection) {
+		return fmt.Errorf("invalid connection fault '%s'", action.Connection)
+	}
+
+	if action.Malformed != "" && !validMalformedMode(action.Malformed) {
+		return fmt.Errorf("invalid malformed response mode '%s'", action.Malformed)
+	}
+
+	if action.Percent != nil && (*action.Percent < 0 || *action.Percent > 100) {
+		return fmt.Errorf("percent must be between 0 and 100, not %f", *action.Percent)
+	}
+
+	rule.fault = &RequestFault{
+		StatusCode: action.Status,
+		Latch:      action.Latch,
+		Connection: action.Connection,
+		Malformed:  action.Malformed,
+		source:     fmt.Sprintf("rule %s", rule.Name),
+	}
+
+	if action.Delay != "" {
+		ms, err := parseLatencyMs(action.Delay)
+
+		if err != nil {
+			return fmt.Errorf("invalid delay: %w", err)
+		}
+
+		rule.fault.DelayMs = int(ms)
+	}
+
+	return nil
+}
+
+// Matches returns true if the rule applies to the request.
+func (rule *FaultRule) Matches(prvReq *ProviderRequest) bool {
+	match := rule.Match
+
+	if match.User != "" && match.User != prvReq.user {
+		return false
+	}
+
+	if match.UserAgent != "" && match.UserAgent != prvReq.userAgent {
+		return false
+	}
+
+	if match.UserAgentContains != "" && !strings.Contains(prvReq.userAgent, match.UserAgentContains) {
+		return false
+	}
+
+	if match.Subrequest != "" && match.Subrequest != prvReq.subrequest {
+		return false
+	}
+
+	if match.Row != nil && *match.Row != prvReq.row {
+		return false
+	}
+
+	if match.Col != nil && *match.Col != prvReq.col {
+		return false
+	}
+
+	return true
+}
+
+// Evaluate finds the first rule matching the request and, if it fires,
+// returns its fault. Otherwise it returns nil.
+func (rs *FaultRuleSet) Evaluate(prvReq *ProviderRequest) *RequestFault {
+	if rs == nil {
+		return nil
+	}
+
+	for _, rule := range rs.Rules {
+		if !rule.Matches(prvReq) {
+			continue
+		}
+
+		if rule.Action.Percent != nil && rand.Float64()*100 >= *rule.Action.Percent {
+			return nil
+		}
+
+		return rule.fault
+	}
+
+	return nil
+}
+
+// FaultRules returns the provider's current rule set (which may be nil).
+// Rule sets are never modified once installed, so it's safe for the caller
+// to hang onto it.
+func (bprv *BaseProvider) FaultRules() *FaultRuleSet {
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	return bprv.rules
+}
+
+// SetFaultRules replaces the provider's rule set. Use nil to remove all the
+// rules.
+func (bprv *BaseProvider) SetFaultRules(rs *FaultRuleSet) {
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	bprv.rules = rs
+
+	count := 0
+
+	if rs != nil {
+		count = len(rs.Rules)
+	}
+
+	bprv.Infof("fault rules changed: %d rules", count)
+}
+
+// HandleAdminRules implements /admin/rules. GET returns the current rules,
+// PUT replaces them with a new set (in YAML or JSON), and DELETE removes all
+// of them.
+func (bprv *BaseProvider) HandleAdminRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		// Nothing to do here, we'll just return the rules below.
+
+	case http.MethodPut:
+		raw, err := io.ReadAll(r.Body)
+
+		if err != nil {
+			adminError(w, http.StatusBadRequest, fmt.Sprintf("couldn't read request: %v", err))
+			return
+		}
+
+		rs, err := ParseFaultRules(raw)
+
+		if err != nil {
+			adminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		bprv.recordChanges(OriginAdminAPI, func() {
+			bprv.SetFaultRules(rs)
+		})
+
+	case http.MethodDelete:
+		bprv.recordChanges(OriginAdminAPI, func() {
+			bprv.SetFaultRules(nil)
+		})
+
+	default:
+		adminError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+		return
+	}
+
+	rs := bprv.FaultRules()
+
+	if rs == nil {
+		rs = &FaultRuleSet{Rules: []*FaultRule{}}
+	}
+
+	adminJSON(w, http.StatusOK, rs)
+}
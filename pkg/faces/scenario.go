@@ -0,0 +1,297 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A ScenarioPhase says "starting at this offset into the scenario, change
+// the fault settings like so". Anything not mentioned in Faults is left
+// alone, so a phase only needs to describe what changes.
+type ScenarioPhase struct {
+	At     time.Duration      `json:"at" yaml:"at"`
+	Faults FaultSettingsPatch `json:"faults" yaml:"faults"`
+}
+
+// A Scenario is a timeline of fault settings for a provider to follow on its
+// own. For example:
+//
+//	repeat: true
+//	length: 120s
+//	phases:
+//	  - at: 0s
+//	    faults: { errorFraction: 0, latched: false }
+//	  - at: 30s
+//	    faults: { errorFraction: 20 }
+//	  - at: 60s
+//	    faults: { latched: true }
+//	  - at: 90s
+//	    faults: { errorFraction: 0, latched: false }
+//
+// Since JSON is YAML, the same thing can be written as JSON too.
+type Scenario struct {
+	Name   string          `json:"name" yaml:"name"`
+	Repeat bool            `json:"repeat" yaml:"repeat"`
+	Length time.Duration   `json:"length" yaml:"length"`
+	Phases []ScenarioPhase `json:"phases" yaml:"phases"`
+}
+
+// LoadScenarioFile reads and validates a Scenario from a YAML or JSON file.
+func LoadScenarioFile(path string) (*Scenario, error) {
+	raw, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseScenario(raw)
+}
+
+// ParseScenario parses and validates a Scenario from YAML or JSON.
+func ParseScenario(raw []byte) (*Scenario, error) {
+	scenario := &Scenario{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+
+	err := decoder.Decode(scenario)
+
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse scenario: %w", err)
+	}
+
+	err = scenario.Validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scenario, nil
+}
+
+// Validate checks that the phases are in order and that every phase would
+// produce sane fault settings. Phases build on each other, so each one is
+// checked on top of all the ones before it (starting from zero-valued
+// settings, since we can't know what the provider will have to begin
+// with). If Length isn't set, it defaults to the start
+// of the last phase (which means that a repeating scenario will start over
+// the moment it reaches its last phase, so you probably want to set it).
+func (scenario *Scenario) Validate() error {
+	if len(scenario.Phases) == 0 {
+		return fmt.Errorf("scenario has no phases")
+	}
+
+	var last time.Duration
+
+	fs := FaultSettings{}
+
+	for i, phase := range scenario.Phases {
+		if phase.At < 0 {
+			return fmt.Errorf("phase %d: at must not be negative", i)
+		}
+
+		if phase.At < last {
+			return fmt.Errorf("phase %d: phases must be in order (%s < %s)", i, phase.At, last)
+		}
+
+		last = phase.At
+
+		fs = phase.Faults.Apply(fs)
+
+		err := fs.Validate()
+
+		if err != nil {
+			return fmt.Errorf("phase %d: %w", i, err)
+		}
+	}
+
+	if scenario.Length == 0 {
+		scenario.Length = last
+	}
+
+	if scenario.Length < last {
+		return fmt.Errorf("length %s is shorter than the last phase (%s)", scenario.Length, last)
+	}
+
+	if scenario.Repeat && scenario.Length <= 0 {
+		return fmt.Errorf("a repeating scenario needs a positive length")
+	}
+
+	return nil
+}
+
+// PhaseAt figures out which phase of the scenario is in effect at elapsed
+// time into the run. It returns the cycle number (always 0 for a scenario
+// that doesn't repeat) and the phase index, which is -1 if we're not yet at
+// the first phase.
+func (scenario *Scenario) PhaseAt(elapsed time.Duration) (int, int) {
+	cycle := 0
+
+	if scenario.Repeat {
+		cycle = int(elapsed / scenario.Length)
+		elapsed = elapsed % scenario.Length
+	}
+
+	phase := -1
+
+	for i, p := range scenario.Phases {
+		if p.At > elapsed {
+			break
+		}
+
+		phase = i
+	}
+
+	return cycle, phase
+}
+
+// restorePatch builds a patch that puts back base's value for every setting
+// that any phase of the scenario changes, and leaves everything else alone.
+// (It leans on every FaultSettingsPatch field having a FaultSettings field
+// of the same name.)
+func (scenario *Scenario) restorePatch(base FaultSettings) FaultSettingsPatch {
+	patch := FaultSettingsPatch{}
+
+	pv := reflect.ValueOf(&patch).Elem()
+	bv := reflect.ValueOf(base)
+
+	for _, phase := range scenario.Phases {
+		fv := reflect.ValueOf(phase.Faults)
+
+		for i := 0; i < fv.NumField(); i++ {
+			if fv.Field(i).IsNil() || !pv.Field(i).IsNil() {
+				continue
+			}
+
+			value := bv.FieldByName(pv.Type().Field(i).Name)
+
+			if value.Kind() == reflect.Pointer {
+				// Center and Edge: an empty profile clears the overrides.
+				if value.IsNil() {
+					value = reflect.ValueOf(&FaultProfile{})
+				}
+
+				pv.Field(i).Set(value)
+				continue
+			}
+
+			ptr := reflect.New(value.Type())
+			ptr.Elem().Set(value)
+			pv.Field(i).Set(ptr)
+		}
+	}
+
+	return patch
+}
+
+// A ScenarioRunner drives a BaseProvider through a Scenario. Its Updater
+// method is a ProviderUpdater, so it gets a look in on every request, and
+// applies a phase's settings when (and only when) that phase begins.
+//
+// Since it only runs when a request arrives, it can miss phases entirely
+// during a quiet spell. Phases are changes, so it replays every phase that
+// it missed, in order; and when a repeating scenario starts a new cycle, it
+// puts the settings that the scenario changes back the way they were before
+// the scenario started, so that nothing from the last cycle lingers.
+// Settings the scenario never touches are left alone, so changes made
+// through the admin API or CONFIG_FILE while it runs stick.
+type ScenarioRunner struct {
+	scenario *Scenario
+	now      func() time.Time
+	start    time.Time
+
+	lock    sync.Mutex
+	restore *FaultSettingsPatch
+	cycle   int
+	phase   int
+}
+
+// NewScenarioRunner creates a ScenarioRunner whose clock starts now. If now
+// is nil, time.Now is used; tests can hand in a fake clock instead.
+func NewScenarioRunner(scenario *Scenario, now func() time.Time) *ScenarioRunner {
+	if now == nil {
+		now = time.Now
+	}
+
+	return &ScenarioRunner{
+		scenario: scenario,
+		now:      now,
+		start:    now(),
+		cycle:    0,
+		phase:    -1,
+	}
+}
+
+// Updater is a ProviderUpdater that applies the current phase of the
+// scenario to the provider, along with any phases it missed, if it hasn't
+// already been applied.
+func (sr *ScenarioRunner) Updater(bprv *BaseProvider) {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+
+	if sr.restore == nil {
+		restore := sr.scenario.restorePatch(bprv.FaultSettings())
+		sr.restore = &restore
+	}
+
+	cycle, phase := sr.scenario.PhaseAt(sr.now().Sub(sr.start))
+
+	if (cycle == sr.cycle) && (phase == sr.phase) {
+		return
+	}
+
+	// In a new cycle, put back what the scenario changed and replay from
+	// the first phase; otherwise, carry on from where we left off.
+	restart := (cycle != sr.cycle)
+	first := sr.phase + 1
+
+	if restart {
+		first = 0
+	}
+
+	sr.cycle = cycle
+	sr.phase = phase
+
+	var err error
+
+	bprv.recordChanges(OriginScenario, func() {
+		if restart {
+			bprv.Infof("Scenario %s: starting cycle %d", sr.scenario.Name, cycle)
+			_, err = bprv.UpdateFaultSettings(*sr.restore)
+		}
+
+		for i := first; (err == nil) && (i <= phase); i++ {
+			bprv.Infof("Scenario %s: cycle %d, entering phase %d", sr.scenario.Name, cycle, i)
+			_, err = bprv.UpdateFaultSettings(sr.scenario.Phases[i].Faults)
+		}
+	})
+
+	if err != nil {
+		// This "can't happen" since we validated the scenario when we
+		// loaded it, unless the other settings clash with it somehow.
+		bprv.Warnf("Scenario %s: couldn't apply cycle %d, phase %d: %s", sr.scenario.Name, cycle, phase, err)
+	}
+}
@@ -0,0 +1,298 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Every scenario that we ship has to load.
+func TestShippedScenariosLoad(t *testing.T) {
+	paths, err := filepath.Glob("../../scenarios/*")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(paths) == 0 {
+		t.Fatal("no scenarios found")
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			_, err := LoadScenarioFile(path)
+
+			if err != nil {
+				t.Errorf("%s: %s", path, err)
+			}
+		})
+	}
+}
+
+// Zero-valued settings have to be valid, since that's what a PUT that leaves
+// things out, or a scenario phase, starts from.
+func TestZeroFaultSettingsValid(t *testing.T) {
+	fs := FaultSettings{}
+
+	err := fs.Validate()
+
+	if err != nil {
+		t.Errorf("zero FaultSettings: %s", err)
+	}
+}
+
+// A phase that only makes sense on top of an earlier one is fine.
+func TestScenarioValidateCumulative(t *testing.T) {
+	_, err := ParseScenario([]byte(`
+phases:
+  - at: 0s
+    faults: { maxRate: 10, rateLimitMode: token-bucket, rateLimitBurst: 5 }
+  - at: 10s
+    faults: { rateLimitKey: user }
+`))
+
+	if err != nil {
+		t.Errorf("cumulative scenario: %s", err)
+	}
+
+	_, err = ParseScenario([]byte(`
+phases:
+  - at: 10s
+    faults: { errorFraction: 20 }
+  - at: 5s
+    faults: { errorFraction: 0 }
+`))
+
+	if err == nil {
+		t.Errorf("out-of-order phases should be rejected")
+	}
+}
+
+func TestScenarioValidate(t *testing.T) {
+	scenario, err := ParseScenario([]byte(`
+phases:
+  - at: 0s
+    faults: { errorFraction: 10 }
+  - at: 30s
+    faults: { errorFraction: 0 }
+`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if scenario.Length != 30*time.Second {
+		t.Errorf("default length: got %s, want 30s", scenario.Length)
+	}
+
+	invalid := map[string]string{
+		"no phases": `name: empty`,
+		"negative at": `
+phases:
+  - at: -5s
+    faults: { errorFraction: 10 }
+`,
+		"bad faults": `
+phases:
+  - at: 0s
+    faults: { errorFraction: 101 }
+`,
+		"bad faults in a later phase": `
+phases:
+  - at: 0s
+    faults: { maxRate: 10 }
+  - at: 5s
+    faults: { rateLimitKey: user }
+`,
+		"too short": `
+length: 10s
+phases:
+  - at: 20s
+    faults: { errorFraction: 10 }
+`,
+		"repeating with no length": `
+repeat: true
+phases:
+  - at: 0s
+    faults: { errorFraction: 10 }
+`,
+	}
+
+	for name, raw := range invalid {
+		_, err := ParseScenario([]byte(raw))
+
+		if err == nil {
+			t.Errorf("%s: should be rejected", name)
+		}
+	}
+}
+
+// fakeClock is a clock that only moves when it's told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func (fc *fakeClock) Now() time.Time {
+	return fc.now
+}
+
+func (fc *fakeClock) Advance(d time.Duration) {
+	fc.now = fc.now.Add(d)
+}
+
+func newTestProvider() *BaseProvider {
+	bprv := &BaseProvider{Name: "Test", Key: "Test"}
+	bprv.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	return bprv
+}
+
+func TestScenarioRunner(t *testing.T) {
+	scenario, err := ParseScenario([]byte(`
+name: test
+repeat: true
+length: 40s
+phases:
+  - at: 5s
+    faults: { errorFraction: 10 }
+  - at: 10s
+    faults: { latchFraction: 50 }
+  - at: 20s
+    faults: { errorFraction: 30 }
+`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bprv := newTestProvider()
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	runner := NewScenarioRunner(scenario, clock.Now)
+
+	type expect struct {
+		errorFraction int
+		latchFraction int
+	}
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		want    expect
+	}{
+		{"before the first phase", 1 * time.Second, expect{0, 0}},
+		{"phase 0", 5 * time.Second, expect{10, 0}},
+		{"still phase 0", 1 * time.Second, expect{10, 0}},
+		{"phase 1", 4 * time.Second, expect{10, 50}},
+		{"phase 2", 10 * time.Second, expect{30, 50}},
+		{"wrap to before phase 0", 20 * time.Second, expect{0, 0}},
+		{"skip straight to phase 2", 25 * time.Second, expect{30, 50}},
+		{"wrap straight to phase 1", 30 * time.Second, expect{10, 50}},
+	}
+
+	for _, step := range steps {
+		clock.Advance(step.advance)
+		runner.Updater(bprv)
+
+		fs := bprv.FaultSettings()
+		got := expect{fs.ErrorFraction, fs.LatchFraction}
+
+		if got != step.want {
+			t.Errorf("%s: got %+v, want %+v", step.name, got, step.want)
+		}
+	}
+}
+
+// A new cycle only puts back what the scenario changes: anything else set
+// while the scenario runs sticks.
+func TestScenarioRunnerKeepsOtherChanges(t *testing.T) {
+	scenario, err := ParseScenario([]byte(`
+name: test
+repeat: true
+length: 20s
+phases:
+  - at: 5s
+    faults: { errorFraction: 10, edge: { errorFraction: 50 } }
+`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bprv := newTestProvider()
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	runner := NewScenarioRunner(scenario, clock.Now)
+
+	err = bprv.SetFaultSettings(FaultSettings{ErrorFraction: 5, LatchFraction: 1})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(6 * time.Second)
+	runner.Updater(bprv)
+
+	latch := 20
+	_, err = bprv.UpdateFaultSettings(FaultSettingsPatch{LatchFraction: &latch})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(16 * time.Second)
+	runner.Updater(bprv) // cycle 1, before phase 0
+
+	fs := bprv.FaultSettings()
+
+	if fs.ErrorFraction != 5 {
+		t.Errorf("errorFraction: got %d, want the original 5 back", fs.ErrorFraction)
+	}
+
+	if fs.Edge != nil {
+		t.Errorf("edge: got %+v, want no overrides", fs.Edge)
+	}
+
+	if fs.LatchFraction != 20 {
+		t.Errorf("latchFraction: got %d, want the admin's 20 to stick", fs.LatchFraction)
+	}
+}
+
+// restorePatch finds FaultSettings fields by name, so every patch field
+// needs a twin.
+func TestFaultSettingsPatchFieldsMatch(t *testing.T) {
+	pt := reflect.TypeOf(FaultSettingsPatch{})
+	st := reflect.TypeOf(FaultSettings{})
+
+	for i := 0; i < pt.NumField(); i++ {
+		pf := pt.Field(i)
+		sf, ok := st.FieldByName(pf.Name)
+
+		if !ok {
+			t.Errorf("FaultSettings has no %s", pf.Name)
+			continue
+		}
+
+		if (pf.Type != sf.Type) && (pf.Type.Elem() != sf.Type) {
+			t.Errorf("%s: patch type %s doesn't match settings type %s", pf.Name, pf.Type, sf.Type)
+		}
+	}
+}
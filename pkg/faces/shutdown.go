@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Graceful shutdown works like this: when we get SIGTERM (or SIGINT), the
+// provider starts draining, which makes it report itself as not ready. It
+// keeps serving requests for DRAIN_PERIOD, so that load balancers (and
+// Kubernetes) have time to notice and stop sending new requests. Then the
+// main server stops gracefully, waiting up to SHUTDOWN_TIMEOUT for requests
+// that are still in flight, and finally the shutdown hooks close everything
+// else (the admin and Prometheus servers, the whisper socket, etc.). A
+// second signal skips straight to exiting.
+
+// IsDraining returns true once the provider has started shutting down.
+func (bprv *BaseProvider) IsDraining() bool {
+	return bprv.draining.Load()
+}
+
+// OnShutdown adds a hook to run at the very end of a graceful shutdown,
+// after the main server has stopped. Hooks run in the order they were
+// added, and should give up when ctx is done.
+func (bprv *BaseProvider) OnShutdown(hook func(ctx context.Context)) {
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	bprv.shutdownHooks = append(bprv.shutdownHooks, hook)
+}
+
+// serveUntilShutdown runs serve, which should block until its server
+// stops, until either it fails or we get a shutdown signal. On a signal, it
+// starts draining and calls drain (if it's not nil) to let the server start
+// turning clients away, waits for the drain period, then calls stop to stop
+// the server gracefully before running the shutdown hooks. stop should give
+// up waiting for in-flight requests when its context is done.
+func (bprv *BaseProvider) serveUntilShutdown(serve func() error, drain func(), stop func(ctx context.Context)) error {
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stopSignals()
+
+	served := make(chan error, 1)
+
+	go func() {
+		served <- serve()
+	}()
+
+	select {
+	case err := <-served:
+		return err
+
+	case <-signalCtx.Done():
+	}
+
+	// Go back to the default signal handling, so that a second signal
+	// kills us right away.
+	stopSignals()
+
+	bprv.Infof("shutting down: draining for %s", bprv.drainPeriod)
+	bprv.draining.Store(true)
+
+	if drain != nil {
+		drain()
+	}
+
+	time.Sleep(bprv.drainPeriod)
+
+	bprv.Infof("stopping (waiting up to %s for in-flight requests)", bprv.shutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), bprv.shutdownTimeout)
+	defer cancel()
+
+	stop(ctx)
+
+	bprv.lock.Lock()
+	hooks := bprv.shutdownHooks
+	bprv.lock.Unlock()
+
+	for _, hook := range hooks {
+		hook(ctx)
+	}
+
+	bprv.Infof("shutdown complete")
+
+	return nil
+}
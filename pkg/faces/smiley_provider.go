@@ -31,7 +31,7 @@ type SmileyProvider struct {
 	smilies map[string]string
 }
 
-func NewSmileyProviderFromEnvironment() *SmileyProvider {
+func NewSmileyProviderFromEnvironment() (*SmileyProvider, error) {
 	sprv := &SmileyProvider{
 		BaseProvider: BaseProvider{
 			Name: "Smiley",
@@ -47,10 +47,13 @@ func NewSmileyProviderFromEnvironment() *SmileyProvider {
 
 	sprv.BaseProvider.SetupFromEnvironment()
 
-	// Set the initial smilies by hand: we explicitly want to use the
-	// fallback smiley if anything goes wrong here.
+	// Set the initial smilies by hand, since SetSmiley wants the lock.
 	smileyName := utils.StringFromEnv("SMILEY", "Grinning")
-	smiley, _ := utils.Smileys.Lookup(smileyName)
+	smiley, found := utils.Smileys.Lookup(smileyName)
+
+	if !found {
+		utils.Env.Check("SMILEY", fmt.Errorf("unknown smiley '%s'", smileyName))
+	}
 
 	sprv.Infof("Starting with smiley %s => %s", smileyName, smiley)
 
@@ -63,7 +66,13 @@ func NewSmileyProviderFromEnvironment() *SmileyProvider {
 	// Set up PUT handler for emoji updates
 	sprv.BaseProvider.SetHTTPPutHandler(sprv.HandlePutRequest)
 
-	return sprv
+	err := sprv.FinishSetup(sprv)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sprv, nil
 }
 
 func (sprv *SmileyProvider) Get(prvReq *ProviderRequest) ProviderResponse {
@@ -164,3 +173,40 @@ func (sprv *SmileyProvider) HandlePutRequest(w http.ResponseWriter, r *http.Requ
 	w.WriteHeader(http.StatusOK)
 	w.Write(respJSON)
 }
+
+// ValidateConfig checks the smiley section of a config file.
+func (sprv *SmileyProvider) ValidateConfig(cfg *ProviderConfig) error {
+	if cfg.Smiley == nil {
+		return nil
+	}
+
+	return validateCellConfig("smiley", cfg.Smiley, utils.Smileys.Lookup)
+}
+
+// ApplyConfig applies the smiley section of a config file.
+func (sprv *SmileyProvider) ApplyConfig(cfg *ProviderConfig) {
+	if cfg.Smiley == nil {
+		return
+	}
+
+	if cfg.Smiley.Center != "" {
+		sprv.SetSmiley("center", cfg.Smiley.Center)
+	}
+
+	if cfg.Smiley.Edge != "" {
+		sprv.SetSmiley("edge", cfg.Smiley.Edge)
+	}
+}
+
+// ConfigSnapshot returns the current smilies, for logging config changes.
+func (sprv *SmileyProvider) ConfigSnapshot() map[string]interface{} {
+	sprv.Lock()
+	defer sprv.Unlock()
+
+	return map[string]interface{}{
+		"smiley": CellConfig{
+			Center: sprv.smilies["center"],
+			Edge:   sprv.smilies["edge"],
+		},
+	}
+}
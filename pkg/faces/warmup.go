@@ -0,0 +1,247 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Warmup makes a freshly started replica slower and flakier than usual
+// for a while, the way a JIT compiler or a cold cache would. It's built from
+// a spec string like
+//
+//	linear:period=60s,delay=500ms
+//	exponential:period=2m,delay=2s,errors=20
+//	step:period=30s,errors=100,code=503
+//
+// where the kind is the shape of the curve:
+//
+//	linear        the penalty tapers off steadily over the period
+//	exponential   the penalty halves every tenth of the period, so most of
+//	              it is gone early on
+//	step          the full penalty for the whole period, then nothing
+//	              (e.g. cold-start 503s)
+//
+// and the parameters are
+//
+//	period   how long the warm-up lasts (required)
+//	delay    extra latency at the very start (as a duration, or in ms)
+//	errors   percentage of requests that fail at the very start
+//	code     the HTTP status for those failures (default 503)
+//
+// At least one of delay and errors is needed. Forced faults (from the fault
+// header or a fault rule) skip the warm-up entirely.
+type Warmup struct {
+	spec    string
+	curve   string
+	period  time.Duration
+	delayMs float64
+	errors  int
+	code    int
+}
+
+// ParseWarmup parses a Warmup spec. An empty spec gives a nil Warmup, which
+// means no warm-up at all.
+func ParseWarmup(spec string) (*Warmup, error) {
+	spec = strings.TrimSpace(spec)
+
+	if spec == "" {
+		return nil, nil
+	}
+
+	curve, rest, _ := strings.Cut(spec, ":")
+	curve = strings.ToLower(strings.TrimSpace(curve))
+
+	switch curve {
+	case "linear", "exponential", "step":
+		// OK
+
+	default:
+		return nil, fmt.Errorf("%s: unknown warmup curve '%s'", spec, curve)
+	}
+
+	wu := &Warmup{spec: spec, curve: curve, code: http.StatusServiceUnavailable}
+
+	for _, field := range strings.Split(rest, ",") {
+		field = strings.TrimSpace(field)
+
+		if field == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(field, "=")
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "period":
+			d, err := time.ParseDuration(value)
+
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("%s: invalid period '%s'", spec, value)
+			}
+
+			wu.period = d
+
+		case "delay":
+			ms, err := parseLatencyMs(value)
+
+			if err != nil {
+				return nil, fmt.Errorf("%s: delay: %w", spec, err)
+			}
+
+			wu.delayMs = ms
+
+		case "errors":
+			n, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+
+			if err != nil || n < 0 || n > 100 {
+				return nil, fmt.Errorf("%s: errors must be between 0 and 100, not '%s'", spec, value)
+			}
+
+			wu.errors = n
+
+		case "code":
+			n, err := strconv.Atoi(value)
+
+			if err != nil || n < 400 || n > 599 {
+				return nil, fmt.Errorf("%s: invalid HTTP error code '%s'", spec, value)
+			}
+
+			wu.code = n
+
+		default:
+			return nil, fmt.Errorf("%s: unknown parameter '%s'", spec, key)
+		}
+	}
+
+	if wu.period == 0 {
+		return nil, fmt.Errorf("%s: period is required", spec)
+	}
+
+	if (wu.delayMs == 0) && (wu.errors == 0) {
+		return nil, fmt.Errorf("%s: needs a delay or errors (or both)", spec)
+	}
+
+	return wu, nil
+}
+
+// String returns the spec that the Warmup was built from.
+func (wu *Warmup) String() string {
+	if wu == nil {
+		return ""
+	}
+
+	return wu.spec
+}
+
+// factor returns how much of the warm-up penalty applies after elapsed
+// time: 1 for the full penalty, down to 0 once the warm-up is over. A nil
+// Warmup always gives 0.
+func (wu *Warmup) factor(elapsed time.Duration) float64 {
+	if wu == nil || elapsed >= wu.period {
+		return 0
+	}
+
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	progress := float64(elapsed) / float64(wu.period)
+
+	switch wu.curve {
+	case "linear":
+		return 1 - progress
+
+	case "exponential":
+		return math.Pow(2, -10*progress)
+	}
+
+	// step
+	return 1
+}
+
+// warmupFactorLocked returns the current warm-up factor. The caller must
+// hold the provider lock.
+func (bprv *BaseProvider) warmupFactorLocked(now time.Time) float64 {
+	return bprv.warmup.factor(now.Sub(bprv.warmupStart))
+}
+
+// warmupFactor returns the current warm-up factor.
+func (bprv *BaseProvider) warmupFactor() float64 {
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	return bprv.warmupFactorLocked(time.Now())
+}
+
+// applyWarmupLocked makes a request slower, and maybe fails it, according
+// to the warm-up. The caller must hold the provider lock.
+func (bprv *BaseProvider) applyWarmupLocked(now time.Time, rstat *BaseRequestStatus) {
+	factor := bprv.warmupFactorLocked(now)
+
+	if factor <= 0 {
+		return
+	}
+
+	wu := bprv.warmup
+
+	rstat.delayMs += int(wu.delayMs * factor)
+
+	if !rstat.ratelimited && !rstat.errored && (wu.errors > 0) {
+		if rand.Float64()*100 < float64(wu.errors)*factor {
+			rstat.errored = true
+			rstat.statusCode = wu.code
+			rstat.message = fmt.Sprintf("%s is warming up", bprv.Name)
+		}
+	}
+}
+
+// RestartWarmup starts the warm-up over again, as if the provider had just
+// started.
+func (bprv *BaseProvider) RestartWarmup() {
+	bprv.lock.Lock()
+	defer bprv.lock.Unlock()
+
+	bprv.warmupStart = time.Now()
+
+	if bprv.warmup != nil {
+		bprv.Infof("warmup restarted: %s", bprv.warmup)
+	}
+}
+
+// HandleAdminWarmup implements /admin/warmup: a POST restarts the warm-up.
+func (bprv *BaseProvider) HandleAdminWarmup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		adminError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+		return
+	}
+
+	bprv.RestartWarmup()
+
+	adminJSON(w, http.StatusOK, map[string]interface{}{
+		"warmup": bprv.FaultSettings().Warmup,
+		"factor": bprv.warmupFactor(),
+	})
+}
@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faces
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseWarmup(t *testing.T) {
+	wu, err := ParseWarmup("exponential:period=2m,delay=2s,errors=20%,code=500")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Warmup{
+		spec:    "exponential:period=2m,delay=2s,errors=20%,code=500",
+		curve:   "exponential",
+		period:  2 * time.Minute,
+		delayMs: 2000,
+		errors:  20,
+		code:    500,
+	}
+
+	if *wu != want {
+		t.Errorf("got %+v, want %+v", *wu, want)
+	}
+
+	wu, err = ParseWarmup("step:period=30s,errors=100")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if wu.code != 503 {
+		t.Errorf("default code: got %d, want 503", wu.code)
+	}
+
+	invalid := []string{
+		"linear",
+		"linear:delay=500ms",
+		"linear:period=60s",
+		"linear:period=0s,delay=500ms",
+		"linear:period=abc,delay=500ms",
+		"linear:period=60s,delay=-1ms",
+		"linear:period=60s,errors=101",
+		"linear:period=60s,errors=20,code=200",
+		"linear:period=60s,delay=500ms,speed=2",
+		"sigmoid:period=60s,delay=500ms",
+	}
+
+	for _, spec := range invalid {
+		_, err := ParseWarmup(spec)
+
+		if err == nil {
+			t.Errorf("%q should be rejected", spec)
+		}
+	}
+
+	wu, err = ParseWarmup("")
+
+	if wu != nil || err != nil {
+		t.Errorf("empty spec: got %v, %v; want nil, nil", wu, err)
+	}
+}
+
+func TestWarmupFactor(t *testing.T) {
+	tests := []struct {
+		spec    string
+		elapsed time.Duration
+		want    float64
+	}{
+		{"linear:period=100s,delay=1s", 0, 1},
+		{"linear:period=100s,delay=1s", 25 * time.Second, 0.75},
+		{"linear:period=100s,delay=1s", 100 * time.Second, 0},
+		{"exponential:period=100s,delay=1s", 10 * time.Second, 0.5},
+		{"exponential:period=100s,delay=1s", 20 * time.Second, 0.25},
+		{"step:period=100s,errors=100", 99 * time.Second, 1},
+		{"step:period=100s,errors=100", 100 * time.Second, 0},
+	}
+
+	for _, tt := range tests {
+		wu, err := ParseWarmup(tt.spec)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := wu.factor(tt.elapsed); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%q after %s: got %f, want %f", tt.spec, tt.elapsed, got, tt.want)
+		}
+	}
+
+	var none *Warmup
+
+	if got := none.factor(0); got != 0 {
+		t.Errorf("nil Warmup: got %f, want 0", got)
+	}
+}
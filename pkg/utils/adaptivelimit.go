@@ -0,0 +1,173 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Adaptive limit algorithms.
+const (
+	AdaptiveAIMD     = "aimd"
+	AdaptiveGradient = "gradient"
+)
+
+// AdaptiveLimit works out a concurrency limit from the latencies it's told
+// about, in the style of Netflix's concurrency-limits library. There are two
+// algorithms:
+//
+//   - aimd (additive increase, multiplicative decrease) adds one to the limit
+//     whenever a request finishes within the target latency while the limit
+//     is at least half used, and cuts the limit by 10% whenever one doesn't.
+//   - gradient compares a short-term average latency with a long-term one.
+//     When the short-term latency climbs, the ratio between them (the
+//     gradient) drops, and the limit shrinks with it; when things are
+//     healthy, the limit grows by about its square root each time.
+//
+// Either way, the limit stays between min and max.
+
+type AdaptiveLimit struct {
+	algorithm string
+	limit     float64
+	min       int
+	max       int
+	target    time.Duration
+	shortRtt  *ewma
+	longRtt   *ewma
+	lock      sync.Mutex
+}
+
+// ewma is an exponentially-weighted moving average over roughly n samples.
+type ewma struct {
+	alpha float64
+	value float64
+	ready bool
+}
+
+func newEWMA(n int) *ewma {
+	return &ewma{alpha: 2.0 / float64(n+1)}
+}
+
+func (e *ewma) add(sample float64) float64 {
+	if !e.ready {
+		e.value = sample
+		e.ready = true
+	} else {
+		e.value += (sample - e.value) * e.alpha
+	}
+
+	return e.value
+}
+
+// NewAdaptiveLimit creates an AdaptiveLimit. The target latency is only
+// used by aimd.
+func NewAdaptiveLimit(algorithm string, initial int, minLimit int, maxLimit int, target time.Duration) (*AdaptiveLimit, error) {
+	if algorithm != AdaptiveAIMD && algorithm != AdaptiveGradient {
+		return nil, fmt.Errorf("unknown adaptive limit algorithm '%s'", algorithm)
+	}
+
+	if minLimit < 1 || maxLimit < minLimit {
+		return nil, fmt.Errorf("adaptive limit needs 1 <= min <= max (got min %d, max %d)", minLimit, maxLimit)
+	}
+
+	if algorithm == AdaptiveAIMD && target <= 0 {
+		return nil, fmt.Errorf("aimd needs a positive target latency")
+	}
+
+	al := &AdaptiveLimit{
+		algorithm: algorithm,
+		min:       minLimit,
+		max:       maxLimit,
+		target:    target,
+		shortRtt:  newEWMA(10),
+		longRtt:   newEWMA(600),
+	}
+
+	al.limit = al.clamp(float64(initial))
+
+	return al, nil
+}
+
+func (al *AdaptiveLimit) String() string {
+	al.lock.Lock()
+	defer al.lock.Unlock()
+	return fmt.Sprintf("AdaptiveLimit@%s: %d (%d-%d)", al.algorithm, int(al.limit), al.min, al.max)
+}
+
+func (al *AdaptiveLimit) Algorithm() string {
+	return al.algorithm
+}
+
+func (al *AdaptiveLimit) Limit() int {
+	al.lock.Lock()
+	defer al.lock.Unlock()
+	return int(al.limit)
+}
+
+// OnSample tells the AdaptiveLimit that a request took rtt to process,
+// while inFlight requests (including it) were being processed. It returns
+// the new limit.
+func (al *AdaptiveLimit) OnSample(rtt time.Duration, inFlight int) int {
+	al.lock.Lock()
+	defer al.lock.Unlock()
+
+	switch al.algorithm {
+	case AdaptiveAIMD:
+		if rtt > al.target {
+			al.limit = al.clamp(math.Floor(al.limit * 0.9))
+		} else if float64(inFlight*2) >= al.limit {
+			al.limit = al.clamp(al.limit + 1)
+		}
+
+	case AdaptiveGradient:
+		// Latencies are floored at a microsecond, so the ratios below are
+		// always defined.
+		sample := math.Max(float64(rtt), float64(time.Microsecond))
+		short := al.shortRtt.add(sample)
+		long := al.longRtt.add(sample)
+
+		// If the long-term average has fallen way behind (say, after a
+		// burst of slow requests), let it catch up faster.
+		if long/short > 2 {
+			al.longRtt.value *= 0.95
+		}
+
+		// If we're not using half the limit, we don't know whether more
+		// would be OK, so leave it be.
+		if float64(inFlight*2) < al.limit {
+			break
+		}
+
+		// Tolerate short-term latency up to 1.5x the long-term average
+		// before backing off.
+		gradient := math.Max(0.5, math.Min(1.0, 1.5*long/short))
+		newLimit := al.limit*gradient + math.Sqrt(al.limit)
+
+		// Smooth things out so one slow request can't crater the limit.
+		al.limit = al.clamp(al.limit*0.8 + newLimit*0.2)
+	}
+
+	return int(al.limit)
+}
+
+func (al *AdaptiveLimit) clamp(limit float64) float64 {
+	return math.Max(float64(al.min), math.Min(float64(al.max), limit))
+}
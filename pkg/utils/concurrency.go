@@ -0,0 +1,183 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull    = errors.New("queue full")
+	ErrQueueTimeout = errors.New("timed out in queue")
+)
+
+// ConcurrencyLimiter limits how many things can be in flight at once. When
+// the limit is reached, callers wait in a bounded FIFO queue, for at most a
+// queue timeout; when the queue is full too, they're turned away at once.
+// A limit of zero means no limit at all.
+//
+// The limit, queue size, and timeout can all be changed on the fly.
+type ConcurrencyLimiter struct {
+	limit    int
+	maxQueue int
+	timeout  time.Duration
+	inFlight int
+	waiters  *list.List // of chan struct{}
+	lock     sync.Mutex
+}
+
+// NewConcurrencyLimiter creates a ConcurrencyLimiter that allows limit
+// things in flight, with up to maxQueue more waiting for at most timeout.
+func NewConcurrencyLimiter(limit int, maxQueue int, timeout time.Duration) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		limit:    limit,
+		maxQueue: maxQueue,
+		timeout:  timeout,
+		waiters:  list.New(),
+	}
+}
+
+func (cl *ConcurrencyLimiter) String() string {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	return fmt.Sprintf("ConcurrencyLimiter@%d/%d, queue %d/%d, timeout %s", cl.inFlight, cl.limit, cl.waiters.Len(), cl.maxQueue, cl.timeout)
+}
+
+// Configure changes the limiter's settings. Raising the limit lets queued
+// callers in right away; lowering it doesn't affect anything already in
+// flight.
+func (cl *ConcurrencyLimiter) Configure(limit int, maxQueue int, timeout time.Duration) {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+
+	cl.limit = limit
+	cl.maxQueue = maxQueue
+	cl.timeout = timeout
+
+	cl.dispatchLocked()
+}
+
+// SetLimit changes just the limit.
+func (cl *ConcurrencyLimiter) SetLimit(limit int) {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+
+	cl.limit = limit
+
+	cl.dispatchLocked()
+}
+
+// Limit returns the current limit.
+func (cl *ConcurrencyLimiter) Limit() int {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	return cl.limit
+}
+
+// InFlight returns how many slots are held right now.
+func (cl *ConcurrencyLimiter) InFlight() int {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	return cl.inFlight
+}
+
+// QueueDepth returns how many callers are waiting in the queue.
+func (cl *ConcurrencyLimiter) QueueDepth() int {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	return cl.waiters.Len()
+}
+
+// Acquire gets a slot, waiting in the queue if need be. It returns
+// ErrQueueFull or ErrQueueTimeout if it couldn't get one, or ctx.Err() if
+// ctx is done first; otherwise the caller must call Release when it's done.
+func (cl *ConcurrencyLimiter) Acquire(ctx context.Context) error {
+	cl.lock.Lock()
+
+	if cl.limit <= 0 || cl.inFlight < cl.limit {
+		cl.inFlight++
+		cl.lock.Unlock()
+		return nil
+	}
+
+	if cl.waiters.Len() >= cl.maxQueue {
+		cl.lock.Unlock()
+		return ErrQueueFull
+	}
+
+	ready := make(chan struct{})
+	element := cl.waiters.PushBack(ready)
+	timeout := cl.timeout
+
+	cl.lock.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var err error
+
+	select {
+	case <-ready:
+		return nil
+
+	case <-timer.C:
+		err = ErrQueueTimeout
+
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+
+	select {
+	case <-ready:
+		// We were handed a slot just as we gave up, so we may as well use
+		// it.
+		return nil
+
+	default:
+		cl.waiters.Remove(element)
+		return err
+	}
+}
+
+// Release gives back a slot from Acquire.
+func (cl *ConcurrencyLimiter) Release() {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+
+	cl.inFlight--
+
+	cl.dispatchLocked()
+}
+
+// dispatchLocked hands out slots to queued callers, for as long as there
+// are slots to give. The caller must hold the lock.
+func (cl *ConcurrencyLimiter) dispatchLocked() {
+	for cl.waiters.Len() > 0 && (cl.limit <= 0 || cl.inFlight < cl.limit) {
+		ready := cl.waiters.Remove(cl.waiters.Front()).(chan struct{})
+		cl.inFlight++
+		close(ready)
+	}
+}
@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// A caller that gives up stops waiting right away, and leaves the queue.
+func TestConcurrencyLimiterAcquireContext(t *testing.T) {
+	cl := NewConcurrencyLimiter(1, 1, time.Minute)
+
+	err := cl.Acquire(context.Background())
+
+	if err != nil {
+		t.Fatalf("first Acquire: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = cl.Acquire(ctx)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("queued Acquire: got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("queued Acquire took %s", elapsed)
+	}
+
+	if depth := cl.QueueDepth(); depth != 0 {
+		t.Errorf("queue depth after giving up: got %d, want 0", depth)
+	}
+
+	cl.Release()
+
+	if inFlight := cl.InFlight(); inFlight != 0 {
+		t.Errorf("in flight after Release: got %d, want 0", inFlight)
+	}
+}
+
+// Queued callers get slots as they free up, and the queue is bounded.
+func TestConcurrencyLimiterQueue(t *testing.T) {
+	cl := NewConcurrencyLimiter(1, 1, time.Minute)
+
+	if err := cl.Acquire(context.Background()); err != nil {
+		t.Fatalf("first Acquire: %s", err)
+	}
+
+	done := make(chan error)
+
+	go func() {
+		done <- cl.Acquire(context.Background())
+	}()
+
+	for cl.QueueDepth() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	err := cl.Acquire(context.Background())
+
+	if !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Acquire with a full queue: got %v, want %v", err, ErrQueueFull)
+	}
+
+	cl.Release()
+
+	if err := <-done; err != nil {
+		t.Errorf("queued Acquire: %s", err)
+	}
+
+	if inFlight := cl.InFlight(); inFlight != 1 {
+		t.Errorf("in flight: got %d, want 1", inFlight)
+	}
+}
@@ -175,6 +175,12 @@ var Defaults = map[string]string{
 	"color-504":  "red",
 	"smiley-504": "Sleeping",
 
+	// 502 errors (BadGateway) are what the face workload reports when the
+	// color & smiley workloads send back garbage, so they get a purple
+	// color and a vomiting face.
+	"color-502":  "purple",
+	"smiley-502": "Vomiting",
+
 	// Ratelimits are yellow with an exploding head.
 	"color-ratelimit":  "yellow",
 	"smiley-ratelimit": "Kaboom",
@@ -17,81 +17,28 @@
 
 package utils
 
-import (
-	"os"
-	"strconv"
-)
+// These read a single value from the environment through Env, so an
+// invalid value is recorded as an error in Env (and the default is used
+// until startup checks Env.Err).
 
 func BoolFromEnv(key string, defaultValue bool) bool {
-	valueStr := os.Getenv(key)
-
-	if valueStr == "" {
-		return defaultValue
-	}
-
-	value, err := strconv.ParseBool(valueStr)
-
-	if err != nil {
-		return defaultValue
-	}
-
-	return value
+	return Env.Bool(key, defaultValue)
 }
 
 func IntFromEnv(key string, defaultValue int) int {
-	valueStr := os.Getenv(key)
-
-	if valueStr == "" {
-		return defaultValue
-	}
-
-	value, err := strconv.Atoi(valueStr)
-
-	if err != nil {
-		return defaultValue
-	}
-
-	return value
+	return Env.Int(key, defaultValue)
 }
 
 // PercentageFromEnv is just like IntFromEnv, but it makes certain that the
 // value is between 0 and 100, inclusive.
 func PercentageFromEnv(key string, defaultValue int) int {
-	value := IntFromEnv(key, defaultValue)
-
-	if value < 0 {
-		value = 0
-	}
-
-	if value > 100 {
-		value = 100
-	}
-
-	return value
+	return Env.Percentage(key, defaultValue)
 }
 
 func FloatFromEnv(key string, defaultValue float64) float64 {
-	valueStr := os.Getenv(key)
-
-	if valueStr == "" {
-		return defaultValue
-	}
-
-	value, err := strconv.ParseFloat(valueStr, 64)
-
-	if err != nil {
-		return defaultValue
-	}
-
-	return value
+	return Env.Float(key, defaultValue)
 }
 
 func StringFromEnv(key string, defaultValue string) string {
-	value := os.Getenv(key)
-
-	if value == "" {
-		return defaultValue
-	}
-
-	return value
+	return Env.String(key, defaultValue)
 }
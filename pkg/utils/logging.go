@@ -23,9 +23,11 @@ import (
 	"os"
 )
 
-func InitLogging() {
-	logLevel := &slog.LevelVar{} // INFO
+// logLevel is shared by everything that logs, so that debug logging can be
+// switched on and off at runtime.
+var logLevel = &slog.LevelVar{} // INFO
 
+func InitLogging() {
 	slogOpts := &slog.HandlerOptions{
 		Level: logLevel,
 	}
@@ -33,8 +35,15 @@ func InitLogging() {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, slogOpts))
 	slog.SetDefault(logger)
 
-	if BoolFromEnv("DEBUG_ENABLED", false) {
+	SetDebugLogging(BoolFromEnv("DEBUG_ENABLED", false))
+}
+
+// SetDebugLogging turns debug logging on or off.
+func SetDebugLogging(enabled bool) {
+	if enabled {
 		logLevel.Set(slog.LevelDebug)
+	} else {
+		logLevel.Set(slog.LevelInfo)
 	}
 }
 
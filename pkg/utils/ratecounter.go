@@ -74,6 +74,11 @@ func (rc *RateCounter) CurrentRate() float64 {
 func (rc *RateCounter) Tick(now time.Time) int {
 	rc.lock.Lock()
 	defer rc.lock.Unlock()
+	return rc.tickLocked(now)
+}
+
+// tickLocked is Tick for a caller that already holds the lock.
+func (rc *RateCounter) tickLocked(now time.Time) int {
 	if rc.firstBucket.IsZero() {
 		rc.firstBucket = now
 	}
@@ -100,8 +105,11 @@ func (rc *RateCounter) Tick(now time.Time) int {
 }
 
 // Mark records that a request has happened. It's a Tick plus incrementing the
-// current bucket.
+// current bucket, all under the lock, so that concurrent Marks don't lose
+// counts or race with the window sliding.
 func (rc *RateCounter) Mark(now time.Time) {
-	bucket := rc.Tick(now)
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	bucket := rc.tickLocked(now)
 	rc.buckets[bucket]++
 }
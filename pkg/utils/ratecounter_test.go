@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// Concurrent Marks all count.
+func TestRateCounterConcurrentMarks(t *testing.T) {
+	rc := NewRateCounter(10)
+	now := time.Now()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				rc.Mark(now)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if rate := rc.CurrentRate(); rate != 100 {
+		t.Errorf("got rate %f, want 100 (1000 marks over 10 seconds)", rate)
+	}
+}
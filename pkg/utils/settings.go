@@ -0,0 +1,402 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Where a Setting's value came from.
+const (
+	SourceDefault     = "default"
+	SourceEnvironment = "environment"
+)
+
+// A Setting is one value read from the environment, along with where it
+// came from.
+type Setting struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Value   string `json:"value"`
+	Default string `json:"default"`
+	Source  string `json:"source"`
+}
+
+// Settings reads typed values from the environment, remembering every one
+// it reads (so that they can all be shown later) and every one that didn't
+// parse (so that startup can fail, rather than quietly using a default).
+type Settings struct {
+	lookup  func(string) (string, bool)
+	environ func() []string
+
+	lock     sync.Mutex
+	settings map[string]*Setting
+	errors   []error
+}
+
+// Env is the Settings for the process environment. Everything that reads
+// the environment should go through it.
+var Env = NewSettings(os.LookupEnv, os.Environ)
+
+// NewSettings creates a Settings that reads values with lookup and lists
+// all the variables with environ (normally os.LookupEnv and os.Environ).
+func NewSettings(lookup func(string) (string, bool), environ func() []string) *Settings {
+	return &Settings{
+		lookup:   lookup,
+		environ:  environ,
+		settings: map[string]*Setting{},
+	}
+}
+
+// read looks up a variable and parses it with parse, recording the result.
+// An empty variable counts as unset. If parse fails, the error is recorded
+// and the caller should use the default.
+func (s *Settings) read(name string, typeName string, defaultStr string, parse func(string) error) {
+	raw, _ := s.lookup(name)
+	raw = strings.TrimSpace(raw)
+
+	setting := &Setting{
+		Name:    name,
+		Type:    typeName,
+		Value:   defaultStr,
+		Default: defaultStr,
+		Source:  SourceDefault,
+	}
+
+	if raw != "" {
+		err := parse(raw)
+
+		if err != nil {
+			s.addError(fmt.Errorf("%s: invalid %s '%s': %w", name, typeName, raw, err))
+		} else {
+			setting.Value = raw
+			setting.Source = SourceEnvironment
+		}
+	}
+
+	s.lock.Lock()
+	s.settings[name] = setting
+	s.lock.Unlock()
+}
+
+func (s *Settings) addError(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.errors = append(s.errors, err)
+}
+
+// IsSet returns true if a variable is set to anything other than an empty
+// string.
+func (s *Settings) IsSet(name string) bool {
+	raw, _ := s.lookup(name)
+	return strings.TrimSpace(raw) != ""
+}
+
+// String reads a string.
+func (s *Settings) String(name string, defaultValue string) string {
+	value := defaultValue
+
+	s.read(name, "string", defaultValue, func(raw string) error {
+		value = raw
+		return nil
+	})
+
+	return value
+}
+
+// Choice reads a string that must be one of choices.
+func (s *Settings) Choice(name string, defaultValue string, choices ...string) string {
+	value := defaultValue
+
+	s.read(name, "choice", defaultValue, func(raw string) error {
+		for _, choice := range choices {
+			if raw == choice {
+				value = raw
+				return nil
+			}
+		}
+
+		return fmt.Errorf("must be one of %s", strings.Join(choices, ", "))
+	})
+
+	return value
+}
+
+// Bool reads a boolean, in any form that strconv.ParseBool accepts.
+func (s *Settings) Bool(name string, defaultValue bool) bool {
+	value := defaultValue
+
+	s.read(name, "bool", strconv.FormatBool(defaultValue), func(raw string) error {
+		v, err := strconv.ParseBool(raw)
+
+		if err != nil {
+			return err
+		}
+
+		value = v
+		return nil
+	})
+
+	return value
+}
+
+// Int reads an integer.
+func (s *Settings) Int(name string, defaultValue int) int {
+	value := defaultValue
+
+	s.read(name, "int", strconv.Itoa(defaultValue), func(raw string) error {
+		v, err := strconv.Atoi(raw)
+
+		if err != nil {
+			return err
+		}
+
+		value = v
+		return nil
+	})
+
+	return value
+}
+
+// Percentage reads an integer between 0 and 100, inclusive.
+func (s *Settings) Percentage(name string, defaultValue int) int {
+	value := defaultValue
+
+	s.read(name, "percentage", strconv.Itoa(defaultValue), func(raw string) error {
+		v, err := strconv.Atoi(strings.TrimSuffix(raw, "%"))
+
+		if err != nil {
+			return err
+		}
+
+		if v < 0 || v > 100 {
+			return fmt.Errorf("must be between 0 and 100")
+		}
+
+		value = v
+		return nil
+	})
+
+	return value
+}
+
+// Float reads a floating-point number.
+func (s *Settings) Float(name string, defaultValue float64) float64 {
+	value := defaultValue
+
+	s.read(name, "float", strconv.FormatFloat(defaultValue, 'f', -1, 64), func(raw string) error {
+		v, err := strconv.ParseFloat(raw, 64)
+
+		if err != nil {
+			return err
+		}
+
+		value = v
+		return nil
+	})
+
+	return value
+}
+
+// Duration reads a duration like "500ms" or "30s".
+func (s *Settings) Duration(name string, defaultValue time.Duration) time.Duration {
+	value := defaultValue
+
+	s.read(name, "duration", defaultValue.String(), func(raw string) error {
+		v, err := time.ParseDuration(raw)
+
+		if err != nil {
+			return err
+		}
+
+		value = v
+		return nil
+	})
+
+	return value
+}
+
+// IntList reads a comma-separated list of integers. Every element has to
+// parse: "10,abc" is an error, not "10".
+func (s *Settings) IntList(name string, defaultValue []int) []int {
+	value := defaultValue
+
+	defaultStrs := make([]string, len(defaultValue))
+
+	for i, v := range defaultValue {
+		defaultStrs[i] = strconv.Itoa(v)
+	}
+
+	s.read(name, "int list", strings.Join(defaultStrs, ","), func(raw string) error {
+		list := []int{}
+
+		for _, elemStr := range strings.Split(raw, ",") {
+			elem, err := strconv.Atoi(strings.TrimSpace(elemStr))
+
+			if err != nil {
+				return fmt.Errorf("'%s' is not an integer", elemStr)
+			}
+
+			list = append(list, elem)
+		}
+
+		value = list
+		return nil
+	})
+
+	return value
+}
+
+// StringList reads a comma-separated list of strings, dropping empty
+// elements.
+func (s *Settings) StringList(name string, defaultValue []string) []string {
+	value := defaultValue
+
+	s.read(name, "string list", strings.Join(defaultValue, ","), func(raw string) error {
+		list := []string{}
+
+		for _, elem := range strings.Split(raw, ",") {
+			elem = strings.TrimSpace(elem)
+
+			if elem != "" {
+				list = append(list, elem)
+			}
+		}
+
+		value = list
+		return nil
+	})
+
+	return value
+}
+
+// Check records an error about a setting that parsed but isn't valid
+// (e.g. because it doesn't make sense alongside some other setting).
+func (s *Settings) Check(name string, err error) {
+	if err != nil {
+		s.addError(fmt.Errorf("%s: %w", name, err))
+	}
+}
+
+// Err returns all the errors found so far, joined together, or nil if
+// everything was fine.
+func (s *Settings) Err() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return errors.Join(s.errors...)
+}
+
+// All returns every setting read so far, sorted by name.
+func (s *Settings) All() []Setting {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	all := make([]Setting, 0, len(s.settings))
+
+	for _, setting := range s.settings {
+		all = append(all, *setting)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Name < all[j].Name
+	})
+
+	return all
+}
+
+// Unknown looks through the environment for variables that look like they
+// were meant for us, but that nothing has read: anything starting with
+// FACES_, or anything within a couple of typos of a setting we know about.
+// It returns a description of each, sorted.
+func (s *Settings) Unknown() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	unknown := []string{}
+
+	for _, kv := range s.environ() {
+		name, _, _ := strings.Cut(kv, "=")
+
+		if _, known := s.settings[name]; known {
+			continue
+		}
+
+		if strings.HasPrefix(name, "FACES_") {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		// Kubernetes adds SMILEY_SERVICE_HOST and friends for every
+		// service, which are close to lots of our names, so skip them.
+		if strings.Contains(name, "_SERVICE_") || strings.HasSuffix(name, "_PORT") || strings.Contains(name, "_PORT_") {
+			continue
+		}
+
+		if len(name) < 6 {
+			continue
+		}
+
+		for known := range s.settings {
+			if editDistance(name, known) <= 2 {
+				unknown = append(unknown, fmt.Sprintf("%s (did you mean %s?)", name, known))
+				break
+			}
+		}
+	}
+
+	sort.Strings(unknown)
+
+	return unknown
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testSettings returns a Settings that reads from env instead of the real
+// environment.
+func testSettings(env map[string]string) *Settings {
+	return NewSettings(
+		func(name string) (string, bool) {
+			value, ok := env[name]
+			return value, ok
+		},
+		func() []string {
+			environ := []string{}
+
+			for name, value := range env {
+				environ = append(environ, name+"="+value)
+			}
+
+			return environ
+		},
+	)
+}
+
+func TestSettingsIntList(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    []int
+		wantErr bool
+	}{
+		{"", []int{1, 2}, false},
+		{"502", []int{502}, false},
+		{"502,503, 504", []int{502, 503, 504}, false},
+		{" 7 , 8 ", []int{7, 8}, false},
+		{"10,abc", []int{1, 2}, true},
+		{"10,,20", []int{1, 2}, true},
+	}
+
+	for _, tt := range tests {
+		s := testSettings(map[string]string{"LIST": tt.raw})
+
+		got := s.IntList("LIST", []int{1, 2})
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("IntList(%q): got %v, want %v", tt.raw, got, tt.want)
+		}
+
+		if err := s.Err(); (err != nil) != tt.wantErr {
+			t.Errorf("IntList(%q): got error %v, want error %v", tt.raw, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSettingsPercentage(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    int
+		wantErr bool
+	}{
+		{"", 5, false},
+		{"0", 0, false},
+		{"20", 20, false},
+		{"20%", 20, false},
+		{"100", 100, false},
+		{"101", 5, true},
+		{"-1", 5, true},
+		{"abc", 5, true},
+	}
+
+	for _, tt := range tests {
+		s := testSettings(map[string]string{"PCT": tt.raw})
+
+		got := s.Percentage("PCT", 5)
+
+		if got != tt.want {
+			t.Errorf("Percentage(%q): got %d, want %d", tt.raw, got, tt.want)
+		}
+
+		if err := s.Err(); (err != nil) != tt.wantErr {
+			t.Errorf("Percentage(%q): got error %v, want error %v", tt.raw, err, tt.wantErr)
+		}
+	}
+}
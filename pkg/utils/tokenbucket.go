@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a rate limiter that allows a steady rate of events with
+// bursts up to a given size, tracked separately for each key. It's
+// implemented with the Generic Cell Rate Algorithm, which is equivalent to a
+// token bucket but only needs to remember one timestamp per key: the
+// "theoretical arrival time" (TAT) at which the bucket for that key will be
+// full again.
+
+type TokenBucket struct {
+	rate     float64
+	burst    int
+	interval time.Duration
+	tats     map[string]time.Time
+	lock     sync.Mutex
+}
+
+// TokenBucketResult is the outcome of trying to take a token from a
+// TokenBucket.
+type TokenBucketResult struct {
+	Allowed    bool
+	Limit      int           // The burst size
+	Remaining  int           // Tokens left in the bucket
+	RetryAfter time.Duration // How long until a token is available (if !Allowed)
+	Reset      time.Duration // How long until the bucket is full again
+}
+
+// pruneThreshold is how many keys a TokenBucket will track before it starts
+// throwing away keys whose buckets have refilled.
+const pruneThreshold = 1024
+
+// NewTokenBucket creates a TokenBucket that refills at rate tokens per
+// second and holds at most burst tokens. The rate must be positive; a burst
+// less than 1 is treated as 1.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &TokenBucket{
+		rate:     rate,
+		burst:    burst,
+		interval: time.Duration(float64(time.Second) / rate),
+		tats:     map[string]time.Time{},
+	}
+}
+
+func (tb *TokenBucket) String() string {
+	return fmt.Sprintf("TokenBucket@%.1f/s, burst %d", tb.rate, tb.burst)
+}
+
+func (tb *TokenBucket) Rate() float64 {
+	return tb.rate
+}
+
+func (tb *TokenBucket) Burst() int {
+	return tb.burst
+}
+
+// Take tries to take a token for the given key at time now.
+func (tb *TokenBucket) Take(key string, now time.Time) TokenBucketResult {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	tat, exists := tb.tats[key]
+
+	if !exists || tat.Before(now) {
+		tat = now
+	}
+
+	// Taking a token pushes the TAT out by one interval. That's allowed as
+	// long as the new TAT is no more than a full bucket's worth of intervals
+	// from now.
+	newTat := tat.Add(tb.interval)
+	allowAt := newTat.Add(-time.Duration(tb.burst) * tb.interval)
+
+	result := TokenBucketResult{Limit: tb.burst}
+
+	if now.Before(allowAt) {
+		result.RetryAfter = allowAt.Sub(now)
+		result.Reset = tat.Sub(now)
+		return result
+	}
+
+	if !exists && len(tb.tats) >= pruneThreshold {
+		tb.pruneLocked(now)
+	}
+
+	tb.tats[key] = newTat
+
+	result.Allowed = true
+	result.Remaining = int(math.Floor(float64(now.Sub(allowAt)) / float64(tb.interval)))
+	result.Reset = newTat.Sub(now)
+
+	return result
+}
+
+// pruneLocked forgets every key whose bucket has already refilled, since a
+// missing key is the same as a full bucket. The caller must hold the lock.
+func (tb *TokenBucket) pruneLocked(now time.Time) {
+	for key, tat := range tb.tats {
+		if !tat.After(now) {
+			delete(tb.tats, key)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// SPDX-FileCopyrightText: 2025 Buoyant Inc.
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright 2022-2025 Buoyant Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// A full bucket allows a burst, then one token per interval.
+func TestTokenBucketBurstAndRefill(t *testing.T) {
+	tb := NewTokenBucket(10, 3)
+	now := time.Unix(1000, 0)
+
+	for i := 0; i < 3; i++ {
+		result := tb.Take("k", now)
+
+		if !result.Allowed {
+			t.Fatalf("take %d of the burst was refused", i+1)
+		}
+
+		if result.Remaining != 2-i {
+			t.Errorf("take %d: got %d remaining, want %d", i+1, result.Remaining, 2-i)
+		}
+	}
+
+	result := tb.Take("k", now)
+
+	if result.Allowed {
+		t.Fatalf("take past the burst was allowed")
+	}
+
+	if result.RetryAfter != 100*time.Millisecond {
+		t.Errorf("RetryAfter: got %s, want 100ms", result.RetryAfter)
+	}
+
+	if result.Reset != 300*time.Millisecond {
+		t.Errorf("Reset: got %s, want 300ms", result.Reset)
+	}
+
+	// One interval later, there's exactly one token.
+	now = now.Add(100 * time.Millisecond)
+
+	if result := tb.Take("k", now); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("take after one interval: got %+v, want allowed with 0 remaining", result)
+	}
+
+	if result := tb.Take("k", now); result.Allowed {
+		t.Errorf("second take after one interval was allowed")
+	}
+
+	// After a long wait, the bucket is full again, but no fuller.
+	now = now.Add(time.Minute)
+
+	if result := tb.Take("k", now); !result.Allowed || result.Remaining != 2 {
+		t.Errorf("take after refilling: got %+v, want allowed with 2 remaining", result)
+	}
+}
+
+// Every key gets its own bucket.
+func TestTokenBucketKeys(t *testing.T) {
+	tb := NewTokenBucket(1, 1)
+	now := time.Unix(1000, 0)
+
+	if !tb.Take("alice", now).Allowed {
+		t.Errorf("alice's first take was refused")
+	}
+
+	if tb.Take("alice", now).Allowed {
+		t.Errorf("alice's second take was allowed")
+	}
+
+	if !tb.Take("bob", now).Allowed {
+		t.Errorf("bob's first take was refused")
+	}
+}
+
+// Pruning forgets only keys whose buckets have refilled.
+func TestTokenBucketPrune(t *testing.T) {
+	tb := NewTokenBucket(1, 1)
+	now := time.Unix(1000, 0)
+
+	tb.Take("old", now)
+	tb.Take("new", now.Add(time.Minute))
+
+	tb.lock.Lock()
+	tb.pruneLocked(now.Add(30 * time.Second))
+	_, hasOld := tb.tats["old"]
+	_, hasNew := tb.tats["new"]
+	tb.lock.Unlock()
+
+	if hasOld || !hasNew {
+		t.Errorf("after pruning: old %v, new %v; want only new", hasOld, hasNew)
+	}
+}